@@ -4,25 +4,67 @@ import (
 	"github.com/boyter/hashit/processor"
 	"github.com/spf13/cobra"
 	"os"
+	"time"
 )
 
 //go:generate go run scripts/include.go
 func main() {
-	//f, _ := os.Create("hashit.pprof")
-	//_ = pprof.StartCPUProfile(f)
-	//defer pprof.StopCPUProfile()
+	noHidden := false
+	noRecursive := false
 
 	rootCmd := &cobra.Command{
 		Use:     "hashit",
 		Short:   "hashit [FILE or DIRECTORY]",
 		Long:    "Hash It!\nBen Boyter <ben@boyter.org>",
 		Version: processor.Version,
+		// Anything which is not a subcommand is a file or directory to process
+		Args: cobra.ArbitraryArgs,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			processor.StartProfiles()
+		},
 		Run: func(cmd *cobra.Command, args []string) {
+			if noHidden {
+				processor.Hidden = false
+			}
+			if noRecursive {
+				processor.Recursive = false
+			}
 			processor.DirFilePaths = args
 			processor.Process()
 		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			processor.StopProfiles()
+		},
 	}
 
+	benchCmd := &cobra.Command{
+		Use:   "bench",
+		Short: "measure the throughput of every hash and strategy on this machine",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			processor.Bench()
+		},
+	}
+	benchCmd.Flags().StringVar(
+		&processor.BenchSize,
+		"size",
+		"64M",
+		"amount of data to hash for each measurement",
+	)
+	benchCmd.Flags().DurationVar(
+		&processor.BenchTime,
+		"time",
+		300*time.Millisecond,
+		"how long to run each measurement for",
+	)
+	benchCmd.Flags().StringVar(
+		&processor.BenchDir,
+		"dir",
+		"",
+		"directory to write the benchmark file to, defaults to the system temp directory",
+	)
+	rootCmd.AddCommand(benchCmd)
+
 	flags := rootCmd.PersistentFlags()
 
 	flags.StringSliceVarP(
@@ -37,15 +79,23 @@ func main() {
 		"format",
 		"f",
 		"text",
-		"set output format [text, json, sum, hashdeep]",
+		"set output format [text, json, ndjson, sum, hashdeep]",
 	)
 	flags.BoolVarP(
 		&processor.Recursive,
 		"recursive",
 		"r",
-		false,
+		true,
 		"recursive subdirectories are traversed",
 	)
+	// Directories are always walked now so the flag only remains so existing scripts keep working
+	_ = flags.MarkDeprecated("recursive", "directories are always recursed into, use --no-recursive to skip them")
+	flags.BoolVar(
+		&noRecursive,
+		"no-recursive",
+		false,
+		"skip directories rather than walking them, useful with wildcards",
+	)
 	flags.BoolVarP(
 		&processor.FileAudit,
 		"file-audit",
@@ -71,7 +121,7 @@ func main() {
 		"audit",
 		"a",
 		"",
-		"audit mode, validates argument against known hashes",
+		"audit against the hashdeep, json or sum output of an earlier run, paths are shown using the same options",
 	)
 	flags.BoolVar(
 		&processor.NoStream,
@@ -83,7 +133,358 @@ func main() {
 		&processor.StreamSize,
 		"stream-size",
 		1000000,
-		"min size of file in bytes where stream processing starts",
+		"min size of file in bytes where memory mapping or stream processing starts",
+	)
+	flags.IntVar(
+		&processor.IOThreads,
+		"io-threads",
+		0,
+		"number of files to read at once, 0 picks based on the storage e.g. 1 for spinning disks",
+	)
+	flags.IntVar(
+		&processor.HashThreads,
+		"hash-threads",
+		0,
+		"number of hashes to calculate at once, 0 uses every core",
+	)
+	flags.StringVar(
+		&processor.MaxRate,
+		"max-rate",
+		"",
+		"maximum bytes per second to read across all files e.g. 50M",
+	)
+	flags.BoolVar(
+		&processor.LowPriority,
+		"low-priority",
+		false,
+		"lower cpu and io priority to avoid slowing down other processes",
+	)
+	flags.IntVar(
+		&processor.FileListQueueSize,
+		"file-list-queue-size",
+		1000,
+		"number of files to queue up ahead of the readers",
+	)
+	flags.StringVar(
+		&processor.Cache,
+		"cache",
+		"",
+		"file to cache hashes in so unchanged files are not read again",
+	)
+	flags.BoolVar(
+		&processor.CacheVerify,
+		"cache-verify",
+		false,
+		"read every file and report any whose hash changed without the file changing",
+	)
+	flags.BoolVar(
+		&processor.CachePrune,
+		"cache-prune",
+		false,
+		"remove cache entries for files which are missing or have changed",
+	)
+	flags.BoolVar(
+		&processor.CacheStats,
+		"cache-stats",
+		false,
+		"print the cache hit ratio to stderr",
+	)
+	flags.BoolVar(
+		&processor.Hardlinks,
+		"hardlinks",
+		false,
+		"hash each hard linked file once and show which paths are links of it",
+	)
+	flags.BoolVar(
+		&processor.Progress,
+		"progress",
+		false,
+		"show progress on stderr, send SIGUSR1 for a status line at any time",
+	)
+	flags.BoolVar(
+		&processor.Prescan,
+		"prescan",
+		false,
+		"total up the files before processing so progress can show a percentage and ETA",
+	)
+	flags.StringVar(
+		&processor.FilesFrom,
+		"files-from",
+		"",
+		"read the list of files to process from this file, - reads it from stdin",
+	)
+	flags.BoolVarP(
+		&processor.NullDelimited,
+		"null",
+		"0",
+		false,
+		"the list of files is separated by NUL as output by find -print0",
+	)
+	flags.StringSliceVar(
+		&processor.Include,
+		"include",
+		[]string{},
+		"only process files found in directories whose relative path matches these globs, ** matches any number of directories",
+	)
+	flags.StringSliceVar(
+		&processor.Exclude,
+		"exclude",
+		[]string{},
+		"skip files and directories whose relative path matches these globs, a trailing / only matches directories",
+	)
+	flags.BoolVar(
+		&processor.IgnoreFiles,
+		"ignore-files",
+		false,
+		"honour .gitignore, .ignore and .hashitignore files and skip .git directories",
+	)
+	flags.StringVar(
+		&processor.Offset,
+		"offset",
+		"",
+		"start hashing each file from this offset e.g. 1M",
+	)
+	flags.StringVar(
+		&processor.Length,
+		"length",
+		"",
+		"hash only this much of each file from the offset e.g. 512M",
+	)
+	flags.IntVar(
+		&processor.SectorSize,
+		"sector-size",
+		0,
+		"read only at offsets and in sizes which are a multiple of this e.g. 512 or 4096, 0 reads normally",
+	)
+	flags.BoolVar(
+		&processor.Summary,
+		"summary",
+		false,
+		"append the files, bytes, time taken and every path which failed or was skipped to the output",
+	)
+	flags.BoolVarP(
+		&processor.Relative,
+		"relative",
+		"l",
+		false,
+		"show paths relative to the directory argument they were found under",
+	)
+	flags.BoolVar(
+		&processor.Absolute,
+		"absolute",
+		false,
+		"show absolute paths",
+	)
+	flags.BoolVarP(
+		&processor.Bare,
+		"bare",
+		"b",
+		false,
+		"show only the name of each file without its directory",
+	)
+	flags.StringVar(
+		&processor.StripPrefix,
+		"strip-prefix",
+		"",
+		"remove this from the start of every path shown",
+	)
+	flags.StringVar(
+		&processor.Prefix,
+		"prefix",
+		"",
+		"add this directory to the start of every path shown",
+	)
+	flags.BoolVar(
+		&processor.ForwardSlashes,
+		"forward-slashes",
+		false,
+		"show paths using / on every OS so manifests can be shared",
+	)
+	flags.StringVar(
+		&processor.MinSize,
+		"min-size",
+		"",
+		"skip files found when walking smaller than this size e.g. 1G",
+	)
+	flags.StringVar(
+		&processor.MaxSize,
+		"max-size",
+		"",
+		"skip files found when walking larger than this size e.g. 100M",
+	)
+	flags.StringVar(
+		&processor.NewerThan,
+		"newer-than",
+		"",
+		"only process files found when walking modified within this duration e.g. 24h or 7d or since a timestamp e.g. 2018-06-01",
+	)
+	flags.StringVar(
+		&processor.OlderThan,
+		"older-than",
+		"",
+		"only process files found when walking modified before this duration ago e.g. 30d or a timestamp e.g. 2018-06-01",
+	)
+	flags.IntVar(
+		&processor.MaxDepth,
+		"max-depth",
+		-1,
+		"how many directories deep to walk, 1 is only the files in the directory, -1 is unlimited",
+	)
+	flags.BoolVar(
+		&processor.Hidden,
+		"hidden",
+		true,
+		"walk files and directories whose names start with a dot",
+	)
+	flags.BoolVar(
+		&noHidden,
+		"no-hidden",
+		false,
+		"skip files and directories whose names start with a dot",
+	)
+	flags.BoolVar(
+		&processor.OneFileSystem,
+		"one-file-system",
+		false,
+		"skip directories on a different filesystem to the one being walked",
+	)
+	flags.StringVar(
+		&processor.Symlinks,
+		"symlinks",
+		"files",
+		"what to do with symlinks [files, follow, skip, record], files does not walk linked directories which follow does, record hashes the path the link points to",
+	)
+	flags.StringVar(
+		&processor.Journal,
+		"journal",
+		"",
+		"file to record finished files and their results in so an interrupted run can be resumed",
+	)
+	flags.BoolVar(
+		&processor.Resume,
+		"resume",
+		false,
+		"skip files which finished according to the journal reusing their results",
+	)
+	flags.StringVar(
+		&processor.Order,
+		"order",
+		"none",
+		"order of results [none, walk, path, size, hash]",
+	)
+	flags.BoolVar(
+		&processor.Reproducible,
+		"reproducible",
+		false,
+		"identical output for identical files, sorts by path and leaves out where it was run from",
+	)
+	flags.StringVar(
+		&processor.Strategy,
+		"strategy",
+		"auto",
+		"how to read files [auto, read, stream, mmap]",
+	)
+	flags.BoolVar(
+		&processor.TreeHash,
+		"tree-hash",
+		false,
+		"print a single digest of the whole tree after the results",
+	)
+	flags.BoolVar(
+		&processor.TreeHashOnly,
+		"tree-hash-only",
+		false,
+		"print only the single digest of the whole tree",
+	)
+	flags.BoolVar(
+		&processor.TreeHashModes,
+		"tree-hash-modes",
+		false,
+		"include file modes in the tree digest",
+	)
+	flags.BoolVar(
+		&processor.TreeHashEmptyDirs,
+		"tree-hash-empty-dirs",
+		false,
+		"include empty directories in the tree digest",
+	)
+	flags.BoolVar(
+		&processor.GoDirHash,
+		"go-dirhash",
+		false,
+		"print the Go module h1: hash of each directory or module zip",
+	)
+	flags.StringVar(
+		&processor.GoDirHashPrefix,
+		"go-dirhash-prefix",
+		"",
+		"module@version prefix for file names when calculating the h1: hash of a directory",
+	)
+	flags.StringVar(
+		&processor.GoSumFile,
+		"go-sum",
+		"",
+		"verify the vendor directory (default vendor) against the h1: hashes in this go.sum file",
+	)
+	flags.BoolVar(
+		&processor.Archives,
+		"archives",
+		false,
+		"hash the files inside zip, tar, tar.gz and tar.bz2 archives (including tar on stdin)",
+	)
+	flags.StringVar(
+		&processor.Decompress,
+		"decompress",
+		"",
+		"hash the decompressed content of gzip, bzip2 and zlib files [also, only]",
+	)
+	flags.BoolVar(
+		&processor.Dedup,
+		"dedup",
+		false,
+		"split files into content defined chunks (FastCDC) and report unique vs total bytes",
+	)
+	flags.IntVar(
+		&processor.ChunkMin,
+		"chunk-min",
+		2048,
+		"minimum chunk size in bytes for dedup",
+	)
+	flags.IntVar(
+		&processor.ChunkAvg,
+		"chunk-avg",
+		8192,
+		"average chunk size in bytes for dedup",
+	)
+	flags.IntVar(
+		&processor.ChunkMax,
+		"chunk-max",
+		65536,
+		"maximum chunk size in bytes for dedup",
+	)
+	flags.BoolVar(
+		&processor.ChunkList,
+		"chunk-list",
+		false,
+		"include the offset, length and SHA256 of every chunk in the output (enables dedup)",
+	)
+	flags.IntVar(
+		&processor.Similar,
+		"similar",
+		0,
+		"cluster images whose perceptual hashes differ by fewer than this many bits",
+	)
+	flags.StringVar(
+		&processor.CPUProfile,
+		"cpu-profile",
+		"",
+		"write a cpu profile to this file",
+	)
+	flags.StringVar(
+		&processor.MemProfile,
+		"mem-profile",
+		"",
+		"write a heap profile to this file when finished",
 	)
 	flags.BoolVarP(
 		&processor.Verbose,
@@ -0,0 +1,168 @@
+package processor
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+)
+
+// Separates the archive from the member inside it when reporting results
+const archiveSeparator = "!"
+
+// Determine if the file should be treated as an archive based on its extension
+func isArchive(filename string) bool {
+	name := strings.ToLower(filename)
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tbz2"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Hashes every file inside the archive without extracting it to disk
+func processArchive(filename string, output chan Result) {
+	var err error
+	if strings.HasSuffix(strings.ToLower(filename), ".zip") {
+		err = processZip(filename, output)
+	} else {
+		file, openErr := os.Open(filename)
+		if openErr != nil {
+			recordError(filename, fmt.Sprintf("Unable to process file %s with error %s", filename, openErr.Error()))
+			return
+		}
+		defer file.Close()
+
+		err = processTar(filename, meteredReader{file}, output)
+	}
+
+	if err != nil {
+		recordError(filename, fmt.Sprintf("Unable to process archive %s with error %s", filename, err.Error()))
+	}
+}
+
+// Zip files store the CRC32 of every member so that is checked against
+// the data as it is hashed
+func processZip(filename string, output chan Result) error {
+	z, err := zip.OpenReader(filename)
+	if err != nil {
+		return err
+	}
+	defer z.Close()
+
+	for _, f := range z.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		name := filename + archiveSeparator + f.Name
+		if Debug {
+			printDebug(fmt.Sprintf("processing %s", name))
+		}
+
+		r, err := f.Open()
+		if err != nil {
+			recordError(name, fmt.Sprintf("Unable to process file %s with error %s", name, err.Error()))
+			continue
+		}
+
+		crc := crc32.NewIEEE()
+		res, err := processStream(name, io.TeeReader(r, crc))
+		_ = r.Close()
+		accountRead(int64(f.CompressedSize64))
+
+		// The zip reader also checks the CRC32 returning an error if it does not match
+		switch {
+		case err != nil:
+			recordError(name, fmt.Sprintf("Unable to process file %s with error %s", name, err.Error()))
+			continue
+		case crc.Sum32() != f.CRC32:
+			recordError(name, fmt.Sprintf("crc32 mismatch for %s stored %08x calculated %08x", name, f.CRC32, crc.Sum32()))
+			continue
+		}
+
+		if Verbose {
+			printVerbose(fmt.Sprintf("crc32 match for %s %08x", name, f.CRC32))
+		}
+		output <- res
+	}
+
+	return nil
+}
+
+// Tar files may be compressed so the first few bytes are checked to
+// determine how to read the stream before walking through it
+func processTar(filename string, reader io.Reader, output chan Result) error {
+	buffered := bufio.NewReader(reader)
+	magic, _ := buffered.Peek(3)
+
+	var stream io.Reader = buffered
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		stream = gz
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		stream = bzip2.NewReader(buffered)
+	}
+
+	tr := tar.NewReader(stream)
+	memberFailed := false
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		// A member which could not be read usually means the stream is broken
+		// so there is no need to report it again for the archive
+		if err != nil && memberFailed {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		memberFailed = false
+
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue
+		}
+
+		name := filename + archiveSeparator + header.Name
+		if Debug {
+			printDebug(fmt.Sprintf("processing %s", name))
+		}
+
+		// Like zip a member which fails is recorded and the rest of the archive
+		// is still hashed as long as the stream lets the next member be found
+		res, err := processStream(name, tr)
+		if err != nil {
+			recordError(name, fmt.Sprintf("Unable to process file %s with error %s", name, err.Error()))
+			memberFailed = true
+			continue
+		}
+
+		output <- res
+	}
+}
+
+// Treats everything piped in as a tar stream hashing each file inside it
+func processArchiveStandardInput(output chan Result) {
+	err := processTar("stdin", meteredReader{os.Stdin}, output)
+	if err != nil {
+		recordError("stdin", fmt.Sprintf("Unable to process archive stdin with error %s", err.Error()))
+	}
+
+	close(output)
+}
@@ -0,0 +1,194 @@
+package processor
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// A tar holding d/a.txt containing hello\n compressed using bzip2 as there is no bzip2 writer in the standard library
+var tarBzip2Hello = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x74, 0x8b,
+	0x30, 0xde, 0x00, 0x00, 0x6f, 0x7b, 0x80, 0xc9, 0x90, 0x00, 0x04, 0x40,
+	0x01, 0xe7, 0x80, 0x00, 0x20, 0x66, 0x44, 0x9e, 0x40, 0x08, 0x08, 0x20,
+	0x00, 0x54, 0x34, 0x9a, 0x46, 0x23, 0xd4, 0x64, 0x0f, 0x50, 0x33, 0x41,
+	0x25, 0x13, 0x46, 0x46, 0x83, 0x41, 0xa1, 0xa6, 0x87, 0xde, 0x44, 0x6a,
+	0x10, 0x3d, 0xc8, 0x42, 0x33, 0xa6, 0x90, 0x25, 0x1b, 0xde, 0x81, 0x0c,
+	0x0a, 0x34, 0x9f, 0x32, 0xb4, 0x4e, 0x60, 0x89, 0x99, 0x03, 0x54, 0xcd,
+	0x4f, 0x88, 0x41, 0xc1, 0x79, 0xeb, 0xd6, 0xfb, 0xf1, 0xbd, 0x99, 0xac,
+	0xc9, 0xa9, 0x11, 0x01, 0x71, 0x77, 0x24, 0x53, 0x85, 0x09, 0x07, 0x48,
+	0xb3, 0x0d, 0xe0,
+}
+
+// Builds a tar of the supplied files in order optionally compressed using gzip
+func tarArchive(files [][2]string, compress bool) []byte {
+	var buf bytes.Buffer
+	var tw *tar.Writer
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(gz)
+	} else {
+		tw = tar.NewWriter(&buf)
+	}
+
+	for _, f := range files {
+		_ = tw.WriteHeader(&tar.Header{Name: f[0], Mode: 0644, Size: int64(len(f[1])), Typeflag: tar.TypeReg})
+		_, _ = tw.Write([]byte(f[1]))
+	}
+	_ = tw.Close()
+	if gz != nil {
+		_ = gz.Close()
+	}
+
+	return buf.Bytes()
+}
+
+// Hashes the archive returning the MD5 of every member keyed by the name reported
+func archiveResults(t *testing.T, name string, content []byte) map[string]string {
+	dir, err := ioutil.TempDir("", "hashit-archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, name)
+	_ = ioutil.WriteFile(filename, content, 0644)
+
+	output := make(chan Result, 10)
+	processArchive(filename, output)
+	close(output)
+
+	results := map[string]string{}
+	for res := range output {
+		results[strings.TrimPrefix(res.File, dir+string(os.PathSeparator))] = res.MD5
+	}
+	return results
+}
+
+func resetArchiveTest() func() {
+	previous := Hash
+	Hash = []string{HashNames.MD5}
+	runErrors = []runIssue{}
+
+	return func() {
+		Hash = previous
+		runErrors = []runIssue{}
+	}
+}
+
+func TestArchiveTarBzip2(t *testing.T) {
+	defer resetArchiveTest()()
+
+	results := archiveResults(t, "release.tar.bz2", tarBzip2Hello)
+
+	if len(results) != 1 || results["release.tar.bz2!d/a.txt"] != "b1946ac92492d2347c6235b4d2611184" {
+		t.Errorf("Expected release.tar.bz2!d/a.txt with b1946ac92492d2347c6235b4d2611184 got %v", results)
+	}
+	if errorCount() != 0 {
+		t.Errorf("Expected no errors got %d", errorCount())
+	}
+}
+
+func TestArchiveStandardInput(t *testing.T) {
+	defer resetArchiveTest()()
+
+	file, err := ioutil.TempFile("", "hashit-stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	_, _ = file.Write(tarArchive([][2]string{{"d/a.txt", "hello\n"}, {"b.txt", "world\n"}}, true))
+	_, _ = file.Seek(0, 0)
+
+	previous := os.Stdin
+	os.Stdin = file
+	defer func() {
+		os.Stdin = previous
+		_ = file.Close()
+	}()
+
+	output := make(chan Result, 10)
+	processArchiveStandardInput(output)
+
+	var names []string
+	for res := range output {
+		names = append(names, res.File)
+	}
+
+	if fmt.Sprint(names) != "[stdin!d/a.txt stdin!b.txt]" {
+		t.Errorf("Expected [stdin!d/a.txt stdin!b.txt] got %v", names)
+	}
+}
+
+func TestArchiveNested(t *testing.T) {
+	defer resetArchiveTest()()
+
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	w, _ := z.Create("inner.txt")
+	_, _ = w.Write([]byte("inner\n"))
+	_ = z.Close()
+	inner := buf.String()
+
+	// Archives inside archives are hashed as members rather than opened
+	results := archiveResults(t, "outer.tar", tarArchive([][2]string{{"inner.zip", inner}, {"a.txt", "hello\n"}}, false))
+
+	expected := fmt.Sprintf("%x", md5.Sum([]byte(inner)))
+	if len(results) != 2 || results["outer.tar!inner.zip"] != expected {
+		t.Errorf("Expected outer.tar!inner.zip with %s got %v", expected, results)
+	}
+}
+
+func TestArchiveCorrupt(t *testing.T) {
+	defer resetArchiveTest()()
+
+	content := tarArchive([][2]string{{"a.txt", "hello\n"}, {"b.txt", string(make([]byte, 4096))}}, true)
+	results := archiveResults(t, "truncated.tar.gz", content[:len(content)/2])
+	if errorCount() != 1 {
+		t.Errorf("Expected 1 error for a truncated tar.gz got %d", errorCount())
+	}
+	if _, ok := results["truncated.tar.gz!b.txt"]; ok {
+		t.Error("Expected no result for a truncated member")
+	}
+
+	archiveResults(t, "garbage.zip", []byte("this is not a zip file"))
+	if errorCount() != 2 {
+		t.Errorf("Expected 2 errors after an invalid zip got %d", errorCount())
+	}
+
+	archiveResults(t, "garbage.tar", bytes.Repeat([]byte("not a tar "), 100))
+	if errorCount() != 3 {
+		t.Errorf("Expected 3 errors after an invalid tar got %d", errorCount())
+	}
+}
+
+func TestArchiveMemberError(t *testing.T) {
+	defer resetArchiveTest()()
+
+	content := tarArchive([][2]string{{"a.txt", "hello\n"}, {"b.txt", string(make([]byte, 16384))}}, false)
+
+	// The second read fails part way through b.txt after a.txt has been hashed
+	output := make(chan Result, 10)
+	err := processTar("failing.tar", iotest.TimeoutReader(bytes.NewReader(content)), output)
+	close(output)
+
+	if err != nil {
+		t.Errorf("Expected the failure to be recorded against the member got %s", err.Error())
+	}
+	if len(runErrors) != 1 || runErrors[0].File != "failing.tar!b.txt" {
+		t.Errorf("Expected 1 error for failing.tar!b.txt got %v", runErrors)
+	}
+	if res := <-output; res.File != "failing.tar!a.txt" || res.MD5 != "b1946ac92492d2347c6235b4d2611184" {
+		t.Errorf("Expected failing.tar!a.txt to be hashed got %s %s", res.File, res.MD5)
+	}
+}
@@ -0,0 +1,402 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A single file from the audit file, the same format as any of the output formats
+type auditEntry struct {
+	File   string
+	Bytes  int64
+	Hashes map[string]string
+	found  bool
+}
+
+// Something about a file which caused the audit to fail
+type auditProblem struct {
+	File   string
+	Status string
+	From   string `json:",omitempty"`
+}
+
+var auditEntries = []*auditEntry{}
+var auditByPath = map[string]*auditEntry{}
+var auditByHash = map[string][]*auditEntry{}
+
+var auditMatched int64
+var auditPartial int64
+var auditChanged int64
+var auditMoved int64
+var auditNew int64
+var auditProblems = []auditProblem{}
+
+// Files without a path in the audit file which could still have moved, only
+// checked once every file has been seen so a path match always takes priority
+var auditUnmatched = []auditPending{}
+
+// A file not found by path in the audit file along with its hashes
+type auditPending struct {
+	File   string
+	Hashes map[string]string
+}
+
+// Hashes in the hashdeep header which hashit can calculate
+var hashDeepColumns = map[string]string{
+	"md5":    HashNames.MD5,
+	"sha1":   HashNames.SHA1,
+	"sha256": HashNames.SHA256,
+}
+
+// Perceptual hashes are all this long so they cannot be told apart in sum files
+const perceptualHashLength = 16
+
+// Hashes in sum files are identified by their length
+var sumHashLengths = map[int]string{
+	32:  HashNames.MD5,
+	40:  HashNames.SHA1,
+	64:  HashNames.SHA256,
+	128: HashNames.SHA512,
+}
+
+// Loads the audit file which can be the hashdeep, json or sum output of an
+// earlier run making sure every hash it contains is calculated for this one
+func loadAuditFile() {
+	content, err := ioutil.ReadFile(AuditFile)
+	if err != nil {
+		printError(fmt.Sprintf("unable to load audit file: %s %s", AuditFile, err.Error()))
+		exit(1)
+	}
+
+	var entries []*auditEntry
+	trimmed := bytes.TrimSpace(content)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("%%%% HASHDEEP")):
+		entries, err = parseAuditHashDeep(content)
+	case bytes.HasPrefix(trimmed, []byte("[")) || bytes.HasPrefix(trimmed, []byte("{")):
+		entries, err = parseAuditJSON(content)
+	default:
+		entries, err = parseAuditSum(content)
+	}
+
+	if err != nil {
+		printError(fmt.Sprintf("unable to load audit file: %s %s", AuditFile, err.Error()))
+		exit(1)
+	}
+
+	if Verbose {
+		printVerbose(fmt.Sprintf("loaded %d files from audit file %s", len(entries), AuditFile))
+	}
+
+	for _, e := range entries {
+		auditEntries = append(auditEntries, e)
+		auditByPath[e.File] = e
+		for name, value := range e.Hashes {
+			auditByHash[name+":"+value] = append(auditByHash[name+":"+value], e)
+			if !hasHash(name) {
+				Hash = append(Hash, name)
+			}
+		}
+	}
+}
+
+// Reads the hashdeep format using the header to work out which column is which
+func parseAuditHashDeep(content []byte) ([]*auditEntry, error) {
+	entries := []*auditEntry{}
+	columns := []string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		if strings.HasPrefix(line, "%%%% ") {
+			if header := strings.TrimPrefix(line, "%%%% "); strings.HasPrefix(header, "size,") {
+				columns = strings.Split(header, ",")
+			}
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if len(columns) == 0 {
+			return nil, fmt.Errorf("missing hashdeep header")
+		}
+
+		// The filename is last and can contain commas itself
+		fields := strings.SplitN(line, ",", len(columns))
+		if len(fields) != len(columns) {
+			return nil, fmt.Errorf("invalid line %s", line)
+		}
+
+		entry := &auditEntry{Hashes: map[string]string{}}
+		for i, column := range columns {
+			switch column {
+			case "size":
+				entry.Bytes, _ = strconv.ParseInt(fields[i], 10, 64)
+			case "filename":
+				entry.File = fields[i]
+			default:
+				if name, ok := hashDeepColumns[column]; ok {
+					entry.Hashes[name] = strings.ToLower(fields[i])
+				}
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// Reads the json format which is the array of results optionally followed by footers
+// or the ndjson format which is a result on each line, ignoring anything without hashes
+// such as the records for paths which failed or the footers
+func parseAuditJSON(content []byte) ([]*auditEntry, error) {
+	results := []Result{}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("[")) {
+		if err := decoder.Decode(&results); err != nil {
+			return nil, err
+		}
+	} else {
+		// Either ndjson with a result per line or json where the results are
+		// a key of an object which holds footers such as the tree hash as well
+		for {
+			var record struct {
+				Result
+				Results []Result
+			}
+			err := decoder.Decode(&record)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, record.Result)
+			results = append(results, record.Results...)
+		}
+	}
+
+	entries := []*auditEntry{}
+	for _, res := range results {
+		if len(resultHashes(res)) == 0 {
+			continue
+		}
+
+		entries = append(entries, &auditEntry{
+			File:   res.File,
+			Bytes:  res.Bytes,
+			Hashes: resultHashes(res),
+		})
+	}
+
+	return entries, nil
+}
+
+// Reads the format used by md5sum, sha1sum and the like working out the hash from its length
+func parseAuditSum(content []byte) ([]*auditEntry, error) {
+	entries := []*auditEntry{}
+	byPath := map[string]*auditEntry{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		// Comments include the footers hashit adds such as the tree hash and summary
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) == 2 && len(fields[0]) == perceptualHashLength {
+			// Similar images share perceptual hashes so they are never used to audit
+			continue
+		}
+
+		name, ok := sumHashLengths[len(fields[0])]
+		if len(fields) != 2 || !ok {
+			return nil, fmt.Errorf("invalid line %s", line)
+		}
+
+		// Binary mode is marked with a * rather than a space before the name
+		file := fields[1]
+		if strings.HasPrefix(file, " ") || strings.HasPrefix(file, "*") {
+			file = file[1:]
+		}
+
+		entry, ok := byPath[file]
+		if !ok {
+			entry = &auditEntry{File: file, Bytes: -1, Hashes: map[string]string{}}
+			byPath[file] = entry
+			entries = append(entries, entry)
+		}
+		entry.Hashes[name] = strings.ToLower(fields[0])
+	}
+
+	return entries, scanner.Err()
+}
+
+// Every cryptographic hash calculated for the result by name
+func resultHashes(res Result) map[string]string {
+	hashes := map[string]string{}
+	for _, h := range streamHashers {
+		if value := h.get(res); value != "" {
+			hashes[h.name] = value
+		}
+	}
+	return hashes
+}
+
+// Counts how many of the hashes both have in common match and how many are compared
+func compareAudit(entry *auditEntry, hashes map[string]string, size int64) (int, int) {
+	matches, compared := 0, 0
+	for name, value := range entry.Hashes {
+		if other, ok := hashes[name]; ok {
+			compared++
+			if other == value {
+				matches++
+			}
+		}
+	}
+
+	if entry.Bytes >= 0 && entry.Bytes != size {
+		matches = 0
+	}
+
+	return matches, compared
+}
+
+// Checks each result against the audit file the same way hashdeep does, a file
+// with the same path and hashes matches, the same path with other hashes has changed
+// and the same hashes somewhere else has moved
+func auditResult(res Result) {
+	file := res.File
+	hashes := resultHashes(res)
+
+	if entry, ok := auditByPath[file]; ok {
+		entry.found = true
+		matches, compared := compareAudit(entry, hashes, res.Bytes)
+		switch {
+		case compared != 0 && matches == compared:
+			auditMatched++
+		case matches != 0:
+			auditPartial++
+			auditProblems = append(auditProblems, auditProblem{File: file, Status: "partial match"})
+		default:
+			auditChanged++
+			auditProblems = append(auditProblems, auditProblem{File: file, Status: "changed"})
+		}
+		return
+	}
+
+	auditUnmatched = append(auditUnmatched, auditPending{File: file, Hashes: hashes})
+}
+
+// Works out which files without a path in the audit file have moved from a known
+// file which was not found, checking them in a fixed order so the result is the
+// same every run
+func auditMoves() {
+	sort.SliceStable(auditUnmatched, func(i, j int) bool {
+		return auditUnmatched[i].File < auditUnmatched[j].File
+	})
+
+	for _, pending := range auditUnmatched {
+		if from := auditMovedFrom(pending.Hashes); from != nil {
+			from.found = true
+			auditMoved++
+			auditProblems = append(auditProblems, auditProblem{File: pending.File, Status: "moved", From: from.File})
+			continue
+		}
+
+		auditNew++
+		auditProblems = append(auditProblems, auditProblem{File: pending.File, Status: "no match"})
+	}
+	auditUnmatched = []auditPending{}
+}
+
+// Finds the first known file not yet found which shares a hash, skipping any already
+// accounted for as identical files such as empty ones share hashes
+func auditMovedFrom(hashes map[string]string) *auditEntry {
+	names := make([]string, 0, len(hashes))
+	for name := range hashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, entry := range auditByHash[name+":"+hashes[name]] {
+			if !entry.found {
+				return entry
+			}
+		}
+	}
+
+	return nil
+}
+
+// Formats the result of the audit to match the output format returning if it passed
+func toAudit() (string, bool) {
+	auditMoves()
+
+	notFound := []auditProblem{}
+	for _, e := range auditEntries {
+		if !e.found {
+			notFound = append(notFound, auditProblem{File: e.File, Status: "known file not found"})
+		}
+	}
+
+	problems := append(auditProblems, notFound...)
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].File < problems[j].File
+	})
+
+	passed := len(problems) == 0
+
+	if jsonFormat() {
+		jsonString, _ := json.Marshal(struct {
+			Passed           bool
+			Matched          int64
+			PartiallyMatched int64
+			Changed          int64
+			Moved            int64
+			New              int64
+			KnownNotFound    int
+			Problems         []auditProblem
+		}{passed, auditMatched, auditPartial, auditChanged, auditMoved, auditNew, len(notFound), problems})
+		return string(jsonString) + "\n", passed
+	}
+
+	var str strings.Builder
+	if passed {
+		str.WriteString("audit passed\n")
+	} else {
+		str.WriteString("audit failed\n")
+	}
+	str.WriteString(fmt.Sprintf("          files matched: %d\n", auditMatched))
+	str.WriteString(fmt.Sprintf("files partially matched: %d\n", auditPartial))
+	str.WriteString(fmt.Sprintf("          files changed: %d\n", auditChanged))
+	str.WriteString(fmt.Sprintf("            files moved: %d\n", auditMoved))
+	str.WriteString(fmt.Sprintf("        new files found: %d\n", auditNew))
+	str.WriteString(fmt.Sprintf("  known files not found: %d\n", len(notFound)))
+
+	for _, p := range problems {
+		if p.From != "" {
+			str.WriteString(fmt.Sprintf("%s: %s from %s\n", p.File, p.Status, p.From))
+		} else {
+			str.WriteString(fmt.Sprintf("%s: %s\n", p.File, p.Status))
+		}
+	}
+
+	return str.String(), passed
+}
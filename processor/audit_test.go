@@ -0,0 +1,154 @@
+package processor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseAuditHashDeep(t *testing.T) {
+	content := "%%%% HASHDEEP-1.0\n%%%% size,md5,sha256,filename\n## comment\n##\n2,b026324c6904b2a9cb4b88d6d61c81d1,4355a46b19d348dc2f57c046f8ef63d4538ebb936000f3c9ee954a27460dd865,a,b.txt\n"
+
+	entries, err := parseAuditHashDeep([]byte(content))
+	if err != nil {
+		t.Fatalf("Expected no error got %s", err.Error())
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry got %d", len(entries))
+	}
+
+	if entries[0].File != "a,b.txt" {
+		t.Errorf("Expected a,b.txt got %s", entries[0].File)
+	}
+
+	if entries[0].Bytes != 2 || entries[0].Hashes["md5"] != "b026324c6904b2a9cb4b88d6d61c81d1" {
+		t.Errorf("Expected size and md5 to be parsed got %d %s", entries[0].Bytes, entries[0].Hashes["md5"])
+	}
+}
+
+func TestParseAuditSum(t *testing.T) {
+	content := "b026324c6904b2a9cb4b88d6d61c81d1  x\n\n4355a46b19d348dc2f57c046f8ef63d4538ebb936000f3c9ee954a27460dd865 *x\n"
+
+	entries, err := parseAuditSum([]byte(content))
+	if err != nil {
+		t.Fatalf("Expected no error got %s", err.Error())
+	}
+
+	if len(entries) != 1 || len(entries[0].Hashes) != 2 {
+		t.Fatalf("Expected 1 entry with 2 hashes got %d", len(entries))
+	}
+
+	if entries[0].Hashes["sha256"] != "4355a46b19d348dc2f57c046f8ef63d4538ebb936000f3c9ee954a27460dd865" {
+		t.Errorf("Expected sha256 to be parsed got %s", entries[0].Hashes["sha256"])
+	}
+}
+
+func TestParseAuditSumFooters(t *testing.T) {
+	content := "b026324c6904b2a9cb4b88d6d61c81d1  x\n" +
+		"c3c3c3c3c3c3c3c3  x\n" +
+		"## tree sha256 3d4b2e4a3be322504a36a14ddc4acb46aef69eb2b0ba39e667b6097c84b7ec31 .\n" +
+		"## dedup (fastcdc min 2048 avg 8192 max 65536)\n" +
+		"## similar images (distance < 10) 0 clusters\n" +
+		"## summary\n" +
+		"##       files 1\n"
+
+	entries, err := parseAuditSum([]byte(content))
+	if err != nil {
+		t.Fatalf("Expected no error got %s", err.Error())
+	}
+
+	if len(entries) != 1 || len(entries[0].Hashes) != 1 {
+		t.Fatalf("Expected 1 entry with 1 hash got %d", len(entries))
+	}
+}
+
+func TestParseAuditJSONWrapped(t *testing.T) {
+	contents := []string{
+		`[{"File":"a","MD5":"b026324c6904b2a9cb4b88d6d61c81d1","Bytes":2}]`,
+		`{"File":"a","MD5":"b026324c6904b2a9cb4b88d6d61c81d1","Bytes":2}` + "\n",
+		`{"Results":[{"File":"a","MD5":"b026324c6904b2a9cb4b88d6d61c81d1","Bytes":2}],"Tree":{"Tree":"a","SHA256":"x","Files":1,"Bytes":2}}` + "\n",
+	}
+
+	for _, content := range contents {
+		entries, err := parseAuditJSON([]byte(content))
+		if err != nil {
+			t.Fatalf("Expected no error got %s", err.Error())
+		}
+
+		if len(entries) != 1 || entries[0].File != "a" || entries[0].Hashes["md5"] != "b026324c6904b2a9cb4b88d6d61c81d1" {
+			t.Errorf("Expected a single entry for a from %s got %d", content, len(entries))
+		}
+	}
+}
+
+// Clears everything loaded from or counted against an audit file
+func resetAudit() {
+	AuditFile = ""
+	auditEntries, auditByPath, auditByHash = []*auditEntry{}, map[string]*auditEntry{}, map[string][]*auditEntry{}
+	auditMatched, auditPartial, auditChanged, auditMoved, auditNew = 0, 0, 0, 0, 0
+	auditProblems, auditUnmatched = []auditProblem{}, []auditPending{}
+}
+
+func TestAuditResultStatuses(t *testing.T) {
+	previousHash := Hash
+	defer func() {
+		Hash = previousHash
+		resetAudit()
+	}()
+	resetAudit()
+
+	empty := "d41d8cd98f00b204e9800998ecf8427e"
+	manifest, _ := ioutil.TempFile("", "hashit-audit")
+	_, _ = manifest.WriteString("b026324c6904b2a9cb4b88d6d61c81d1  a\n26ab0db90d72e28ad0ba1e22ee510510  b\n" + empty + "  e1\n" + empty + "  e2\n")
+	_ = manifest.Close()
+	defer os.Remove(manifest.Name())
+
+	AuditFile = manifest.Name()
+	loadAuditFile()
+
+	// The new empty file is seen before e1 which must still match by path
+	for _, res := range []Result{
+		{File: "e3", MD5: empty},
+		{File: "a", MD5: "6d7fce9fee471194aa8b5b6e47267f03", Bytes: 2},
+		{File: "c", MD5: "26ab0db90d72e28ad0ba1e22ee510510", Bytes: 2},
+		{File: "e1", MD5: empty},
+	} {
+		auditResult(res)
+	}
+
+	output, passed := toAudit()
+	if passed {
+		t.Errorf("Expected audit to fail")
+	}
+
+	expected := "a: changed\nc: moved from b\ne3: moved from e2\n"
+	if !strings.HasSuffix(output, "  known files not found: 0\n"+expected) {
+		t.Errorf("Expected problems %q got %s", expected, output)
+	}
+}
+
+func TestAuditStripPrefixRoundTrip(t *testing.T) {
+	original := processTestDir(t)
+	defer os.RemoveAll(original)
+	copied := processTestDir(t)
+	defer os.RemoveAll(copied)
+	defer func() {
+		StripPrefix, Prefix = "", ""
+		resetAudit()
+	}()
+	resetAudit()
+
+	Hash = []string{HashNames.MD5}
+	Format = "sum"
+	manifest := filepath.Join(original, "manifest")
+	_ = ioutil.WriteFile(manifest, []byte(processOutput(t, filepath.Join(original, "a"), filepath.Join(original, "b"))), 0644)
+
+	// The prefix has no trailing separator so one has to be added between it and each path
+	AuditFile, StripPrefix, Prefix = manifest, copied, original
+	if output := processOutput(t, filepath.Join(copied, "a"), filepath.Join(copied, "b")); !strings.HasPrefix(output, "audit passed\n") {
+		t.Errorf("Expected audit passed got %s", output)
+	}
+}
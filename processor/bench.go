@@ -0,0 +1,227 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+)
+
+// BenchSize is the amount of data hashed by the benchmark for each measurement
+var BenchSize = "64M"
+
+// BenchTime is how long each measurement runs for
+var BenchTime = 300 * time.Millisecond
+
+// BenchDir is where the benchmark file is written so the storage of interest can be tested
+var BenchDir = ""
+
+// Throughput in MB/s of one algorithm using each of the strategies
+type benchAlgorithm struct {
+	Algorithm string
+	Read      float64
+	Parallel  float64
+	Scanner   float64
+	Mmap      float64
+}
+
+// Throughput in MB/s of streaming using a particular buffer size
+type benchBuffer struct {
+	BufferSize int
+	Scanner    float64
+}
+
+// Throughput in MB/s of the default hashes for files of a particular size
+type benchFileSize struct {
+	Size    int64
+	Read    float64
+	Scanner float64
+	Mmap    float64
+}
+
+type benchResults struct {
+	Size                  int64
+	Algorithms            []benchAlgorithm
+	BufferSizes           []benchBuffer
+	FileSizes             []benchFileSize
+	RecommendedStreamSize int64
+}
+
+// Runs the operation repeatedly for at least BenchTime returning the throughput in MB/s
+func benchMeasure(size int64, operation func()) float64 {
+	// Run once first so caches are warm and anything lazy is set up
+	operation()
+
+	count := 0
+	start := time.Now()
+	for time.Since(start) < BenchTime || count == 0 {
+		operation()
+		count++
+	}
+
+	return float64(size) * float64(count) / time.Since(start).Seconds() / 1000000
+}
+
+// Measures how fast the file can be hashed using the strategy with the currently selected hashes
+func benchStrategy(filename string, size int64, strategy string) float64 {
+	return benchMeasure(size, func() {
+		file, err := os.Open(filename)
+		if err != nil {
+			return
+		}
+		defer file.Close()
+
+		switch strategy {
+		case "read":
+			content, _ := readAll(file, size+bytes.MinRead)
+			_, _ = processReadFile(filename, &content)
+		case "parallel":
+			content, _ := readAll(file, size+bytes.MinRead)
+			_, _ = processReadFileParallel(filename, &content)
+		case "scanner":
+			_, _ = processStream(filename, file)
+		case "mmap":
+			_, _ = processMemoryMap(filename, file)
+		}
+	})
+}
+
+// Writes a file of random data returning its name
+func benchFile(size int64) (string, error) {
+	file, err := ioutil.TempFile(BenchDir, "hashit-bench")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	data := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(data)
+	_, err = file.Write(data)
+
+	return file.Name(), err
+}
+
+// Bench measures the throughput of every algorithm and strategy on this machine
+// and recommends a stream size based on where memory mapping beats reading
+func Bench() {
+	size, err := parseSize(BenchSize)
+	if err != nil || size <= 0 {
+		printError(fmt.Sprintf("invalid bench size %s", BenchSize))
+		exit(1)
+	}
+
+	setupHashThreads()
+
+	filename, err := benchFile(size)
+	if filename != "" {
+		defer os.Remove(filename)
+	}
+	if err != nil {
+		printError(fmt.Sprintf("unable to create bench file: %s", err.Error()))
+		exit(1)
+	}
+
+	previousHash := Hash
+	previousBuffer := streamBufferSize
+	defer func() {
+		Hash = previousHash
+		streamBufferSize = previousBuffer
+	}()
+
+	results := benchResults{Size: size}
+
+	algorithms := []string{}
+	for _, h := range streamHashers {
+		algorithms = append(algorithms, h.name)
+	}
+	algorithms = append(algorithms, "all")
+
+	for _, algorithm := range algorithms {
+		Hash = []string{algorithm}
+		results.Algorithms = append(results.Algorithms, benchAlgorithm{
+			Algorithm: algorithm,
+			Read:      benchStrategy(filename, size, "read"),
+			Parallel:  benchStrategy(filename, size, "parallel"),
+			Scanner:   benchStrategy(filename, size, "scanner"),
+			Mmap:      benchStrategy(filename, size, "mmap"),
+		})
+	}
+
+	// The remaining measurements use the default hashes
+	Hash = []string{HashNames.MD5, HashNames.SHA1, HashNames.SHA256, HashNames.SHA512}
+
+	for _, buffer := range []int{65536, 262144, 1048576, 4194304, 16777216} {
+		streamBufferSize = buffer
+		results.BufferSizes = append(results.BufferSizes, benchBuffer{
+			BufferSize: buffer,
+			Scanner:    benchStrategy(filename, size, "scanner"),
+		})
+	}
+	streamBufferSize = previousBuffer
+
+	for _, fileSize := range []int64{65536, 262144, 1048576, 4194304, 16777216} {
+		sized, err := benchFile(fileSize)
+		if err != nil {
+			os.Remove(sized)
+			continue
+		}
+
+		res := benchFileSize{
+			Size:    fileSize,
+			Read:    benchStrategy(sized, fileSize, "parallel"),
+			Scanner: benchStrategy(sized, fileSize, "scanner"),
+			Mmap:    benchStrategy(sized, fileSize, "mmap"),
+		}
+		os.Remove(sized)
+		results.FileSizes = append(results.FileSizes, res)
+	}
+	results.RecommendedStreamSize = recommendStreamSize(results.FileSizes)
+
+	if strings.ToLower(Format) == "json" {
+		jsonString, _ := json.Marshal(results)
+		fmt.Println(string(jsonString))
+		return
+	}
+
+	fmt.Print(benchTable(results))
+}
+
+// Files larger than the stream size are memory mapped so recommend the largest size where
+// reading is still faster, never going below the smallest size measured as 0 would map everything
+func recommendStreamSize(sizes []benchFileSize) int64 {
+	var recommended int64
+	for i, f := range sizes {
+		if i == 0 || f.Read >= f.Mmap {
+			recommended = f.Size
+		}
+	}
+
+	return recommended
+}
+
+func benchTable(results benchResults) string {
+	var str strings.Builder
+
+	str.WriteString(fmt.Sprintf("throughput in MB/s hashing %s\n\n", formatBytes(results.Size)))
+	str.WriteString(fmt.Sprintf("%-12s %10s %10s %10s %10s\n", "algorithm", "read", "parallel", "scanner", "mmap"))
+	for _, a := range results.Algorithms {
+		str.WriteString(fmt.Sprintf("%-12s %10.1f %10.1f %10.1f %10.1f\n", a.Algorithm, a.Read, a.Parallel, a.Scanner, a.Mmap))
+	}
+
+	str.WriteString(fmt.Sprintf("\n%-12s %10s\n", "buffer", "scanner"))
+	for _, b := range results.BufferSizes {
+		str.WriteString(fmt.Sprintf("%-12s %10.1f\n", formatBytes(int64(b.BufferSize)), b.Scanner))
+	}
+
+	str.WriteString(fmt.Sprintf("\n%-12s %10s %10s %10s\n", "file size", "read", "scanner", "mmap"))
+	for _, f := range results.FileSizes {
+		str.WriteString(fmt.Sprintf("%-12s %10.1f %10.1f %10.1f\n", formatBytes(f.Size), f.Read, f.Scanner, f.Mmap))
+	}
+
+	str.WriteString(fmt.Sprintf("\nrecommended --stream-size %d\n", results.RecommendedStreamSize))
+	return str.String()
+}
@@ -0,0 +1,36 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRecommendStreamSize(t *testing.T) {
+	cases := []struct {
+		sizes    []benchFileSize
+		expected int64
+	}{
+		{[]benchFileSize{{Size: 65536, Read: 10, Mmap: 20}, {Size: 1048576, Read: 10, Mmap: 30}}, 65536},
+		{[]benchFileSize{{Size: 65536, Read: 30, Mmap: 20}, {Size: 1048576, Read: 30, Mmap: 20}, {Size: 4194304, Read: 10, Mmap: 20}}, 1048576},
+		{[]benchFileSize{}, 0},
+	}
+
+	for _, c := range cases {
+		if res := recommendStreamSize(c.sizes); res != c.expected {
+			t.Errorf("Expected %d got %d", c.expected, res)
+		}
+	}
+}
+
+func TestBenchTable(t *testing.T) {
+	res := benchTable(benchResults{
+		Size:                  1048576,
+		Algorithms:            []benchAlgorithm{{Algorithm: "md5", Read: 1, Parallel: 2, Scanner: 3, Mmap: 4}},
+		FileSizes:             []benchFileSize{{Size: 65536, Read: 1, Scanner: 2, Mmap: 3}},
+		RecommendedStreamSize: 65536,
+	})
+
+	if !strings.Contains(res, "recommended --stream-size 65536") {
+		t.Errorf("Expected recommended stream size got %s", res)
+	}
+}
@@ -0,0 +1,198 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// Everything about a file which is checked to decide if the cached hashes can be reused
+type cacheIdentity struct {
+	Device  uint64
+	Inode   uint64
+	Size    int64
+	MtimeNs int64
+	CtimeNs int64
+}
+
+type cacheEntry struct {
+	cacheIdentity
+	Hashes map[string]string
+}
+
+var cacheEntries = map[string]cacheEntry{}
+var cacheSeen = map[string]bool{}
+var cacheMutex = sync.Mutex{}
+var cacheLoaded = false
+var cacheHits int64
+var cacheMisses int64
+var cacheMismatches int64
+
+// Loads the cache file if there is one, a missing file is an empty cache
+func loadCache() {
+	content, err := ioutil.ReadFile(Cache)
+	if os.IsNotExist(err) {
+		cacheLoaded = true
+		return
+	}
+	if err != nil {
+		printError(fmt.Sprintf("unable to load cache file: %s %s", Cache, err.Error()))
+		exit(1)
+	}
+
+	if err := json.Unmarshal(content, &cacheEntries); err != nil {
+		printError(fmt.Sprintf("unable to parse cache file: %s %s", Cache, err.Error()))
+		exit(1)
+	}
+
+	cacheLoaded = true
+}
+
+// Writes the cache to a temporary file and then renames it over the old one
+// so the cache is never left half written
+func saveCache() {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	if CachePrune {
+		for path, entry := range cacheEntries {
+			if cacheSeen[path] {
+				continue
+			}
+
+			fi, err := os.Lstat(path)
+			if err != nil || fileIdentity(fi) != entry.cacheIdentity {
+				delete(cacheEntries, path)
+			}
+		}
+	}
+
+	content, err := json.Marshal(cacheEntries)
+	if err != nil {
+		printError(fmt.Sprintf("unable to save cache file: %s %s", Cache, err.Error()))
+		return
+	}
+
+	tmp := Cache + ".tmp"
+	if err := ioutil.WriteFile(tmp, content, 0600); err != nil {
+		printError(fmt.Sprintf("unable to save cache file: %s %s", Cache, err.Error()))
+		return
+	}
+	if err := os.Rename(tmp, Cache); err != nil {
+		printError(fmt.Sprintf("unable to save cache file: %s %s", Cache, err.Error()))
+	}
+}
+
+func cacheKey(filename string) string {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return filename
+	}
+	return abs
+}
+
+// Every hash the cache can store along with how to get it from and put it into a result
+func cacheHashes() []streamHasher {
+	return append(streamHashers,
+		streamHasher{name: HashNames.AHash, get: func(r Result) string { return r.AHash }, set: func(r *Result, s string) { r.AHash = s }},
+		streamHasher{name: HashNames.DHash, get: func(r Result) string { return r.DHash }, set: func(r *Result, s string) { r.DHash = s }},
+		streamHasher{name: HashNames.PHash, get: func(r Result) string { return r.PHash }, set: func(r *Result, s string) { r.PHash = s }},
+	)
+}
+
+// Returns the cached hashes if the file has not changed and every requested hash is cached
+func cacheLookup(filename string, fi os.FileInfo) (Result, bool) {
+	if !cacheLoaded || CacheVerify || Dedup {
+		return Result{}, false
+	}
+
+	key := cacheKey(filename)
+
+	cacheMutex.Lock()
+	entry, ok := cacheEntries[key]
+	cacheMutex.Unlock()
+
+	if !ok || entry.cacheIdentity != fileIdentity(fi) {
+		atomic.AddInt64(&cacheMisses, 1)
+		return Result{}, false
+	}
+
+	result := Result{}
+	for _, h := range cacheHashes() {
+		if !calculateHash(h.name) {
+			continue
+		}
+
+		// Hashes which have never been calculated are missing while perceptual
+		// hashes of files which are not images are stored as empty
+		value, ok := entry.Hashes[h.name]
+		if !ok {
+			atomic.AddInt64(&cacheMisses, 1)
+			return Result{}, false
+		}
+		h.set(&result, value)
+	}
+
+	cacheMutex.Lock()
+	cacheSeen[key] = true
+	cacheMutex.Unlock()
+
+	atomic.AddInt64(&cacheHits, 1)
+	return result, true
+}
+
+// Stores the hashes which were calculated. If the file looks unchanged but
+// a hash differs from the cached one the content changed without its
+// metadata changing which is reported as it usually means corruption
+func cacheStore(filename string, fi os.FileInfo, result Result) {
+	if !cacheLoaded {
+		return
+	}
+
+	key := cacheKey(filename)
+	identity := fileIdentity(fi)
+
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	entry, ok := cacheEntries[key]
+	if !ok || entry.cacheIdentity != identity {
+		entry = cacheEntry{cacheIdentity: identity, Hashes: map[string]string{}}
+	}
+
+	for _, h := range cacheHashes() {
+		if !calculateHash(h.name) {
+			continue
+		}
+
+		value := h.get(result)
+		if previous, ok := entry.Hashes[h.name]; ok && previous != value {
+			atomic.AddInt64(&cacheMismatches, 1)
+			printError(fmt.Sprintf("cache mismatch for %s %s cached %s calculated %s", filename, h.name, previous, value))
+		}
+		entry.Hashes[h.name] = value
+	}
+
+	cacheEntries[key] = entry
+	cacheSeen[key] = true
+}
+
+// Prints how effective the cache was to stderr so it does not mix with the results
+func printCacheStats() {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	hits := atomic.LoadInt64(&cacheHits)
+	misses := atomic.LoadInt64(&cacheMisses)
+
+	ratio := 0.0
+	if hits+misses != 0 {
+		ratio = float64(hits) / float64(hits+misses) * 100
+	}
+
+	fmt.Fprintf(os.Stderr, "cache %d hits %d misses %.1f%% hit ratio %d mismatches %d entries\n", hits, misses, ratio, atomic.LoadInt64(&cacheMismatches), len(cacheEntries))
+}
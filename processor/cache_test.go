@@ -0,0 +1,44 @@
+package processor
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestCacheStoreLookup(t *testing.T) {
+	previous := Hash
+	Hash = []string{HashNames.MD5}
+	cacheLoaded = true
+	defer func() {
+		Hash = previous
+		cacheLoaded = false
+		cacheEntries = map[string]cacheEntry{}
+	}()
+
+	file, _ := ioutil.TempFile("", "hashit")
+	defer os.Remove(file.Name())
+	_, _ = file.WriteString("hello")
+	_ = file.Close()
+
+	fi, _ := os.Stat(file.Name())
+
+	if _, ok := cacheLookup(file.Name(), fi); ok {
+		t.Error("Expected cache miss before storing")
+	}
+
+	cacheStore(file.Name(), fi, Result{MD5: "5d41402abc4b2a76b9719d911017c592"})
+
+	res, ok := cacheLookup(file.Name(), fi)
+	if !ok {
+		t.Error("Expected cache hit after storing")
+	}
+	if res.MD5 != "5d41402abc4b2a76b9719d911017c592" {
+		t.Errorf("Expected 5d41402abc4b2a76b9719d911017c592 got %s", res.MD5)
+	}
+
+	Hash = []string{HashNames.MD5, HashNames.SHA1}
+	if _, ok := cacheLookup(file.Name(), fi); ok {
+		t.Error("Expected cache miss when a requested hash is not cached")
+	}
+}
@@ -0,0 +1,203 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"strings"
+	"sync"
+)
+
+// Random values used by the gear rolling hash, generated once from a fixed
+// seed so that chunk boundaries are the same on every run and every machine
+var gearTable = func() [256]uint64 {
+	var table [256]uint64
+	seed := uint64(0x9e3779b97f4a7c15)
+	for i := range table {
+		// splitmix64
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// Holds the dedup statistics for every chunk seen over the whole run
+var dedupIndex = map[[sha256.Size]byte]bool{}
+var dedupMutex = sync.Mutex{}
+var dedupChunks int64
+var dedupUniqueChunks int64
+var dedupBytes int64
+var dedupUniqueBytes int64
+
+// Splits a stream into content defined chunks using FastCDC with normalised
+// chunking so that inserting or removing data only changes nearby chunks
+type chunker struct {
+	buffer  []byte
+	offset  int64
+	maskS   uint64
+	maskL   uint64
+	results []Chunk
+}
+
+func newChunker() *chunker {
+	// Round the average to the nearest power of two to get the mask size
+	b := uint(bits.Len64(uint64(ChunkAvg))) - 1
+	if uint64(ChunkAvg) >= uint64(3)<<(b-1) {
+		b++
+	}
+
+	return &chunker{
+		maskS: chunkMask(b + 2),
+		maskL: chunkMask(b - 2),
+	}
+}
+
+// Mask with the highest bits set as those carry the most history in the gear hash
+func chunkMask(size uint) uint64 {
+	if size >= 64 {
+		return ^uint64(0)
+	}
+	return ((uint64(1) << size) - 1) << (64 - size)
+}
+
+// Returns the length of the first chunk in data
+func (c *chunker) cut(data []byte) int {
+	n := len(data)
+	if n <= ChunkMin {
+		return n
+	}
+	if n > ChunkMax {
+		n = ChunkMax
+	}
+
+	normal := ChunkAvg
+	if n < normal {
+		normal = n
+	}
+
+	var fp uint64
+	i := ChunkMin
+	for ; i < normal; i++ {
+		fp = (fp << 1) + gearTable[data[i]]
+		if fp&c.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		fp = (fp << 1) + gearTable[data[i]]
+		if fp&c.maskL == 0 {
+			return i + 1
+		}
+	}
+
+	return n
+}
+
+// Adds data to the chunker emitting any chunks which are now complete
+func (c *chunker) Write(p []byte) (int, error) {
+	c.buffer = append(c.buffer, p...)
+
+	start := 0
+	for len(c.buffer)-start >= ChunkMax {
+		n := c.cut(c.buffer[start:])
+		c.emit(c.buffer[start : start+n])
+		start += n
+	}
+
+	// Move what is left to the front so the buffer does not keep growing
+	c.buffer = c.buffer[:copy(c.buffer, c.buffer[start:])]
+	return len(p), nil
+}
+
+// Emits whatever is left as the final chunks and returns every chunk
+func (c *chunker) Close() []Chunk {
+	for len(c.buffer) != 0 {
+		n := c.cut(c.buffer)
+		c.emit(c.buffer[:n])
+		c.buffer = c.buffer[n:]
+	}
+
+	return c.results
+}
+
+func (c *chunker) emit(data []byte) {
+	sum := sha256.Sum256(data)
+	length := int64(len(data))
+
+	dedupMutex.Lock()
+	dedupChunks++
+	dedupBytes += length
+	if !dedupIndex[sum] {
+		dedupIndex[sum] = true
+		dedupUniqueChunks++
+		dedupUniqueBytes += length
+	}
+	dedupMutex.Unlock()
+
+	if ChunkList {
+		c.results = append(c.results, Chunk{
+			Offset: c.offset,
+			Length: length,
+			SHA256: hex.EncodeToString(sum[:]),
+		})
+	}
+
+	c.offset += length
+}
+
+// Chunks content which is already in memory
+func processChunks(content *[]byte) []Chunk {
+	c := newChunker()
+	_, _ = c.Write(*content)
+	return c.Close()
+}
+
+// Checks the chunk sizes make sense before any processing starts
+func validateChunkSizes() error {
+	if ChunkMin <= 0 || ChunkAvg < 64 || ChunkMin > ChunkAvg || ChunkAvg > ChunkMax {
+		return fmt.Errorf("chunk sizes must satisfy 0 < min <= avg <= max with avg at least 64 got min %d avg %d max %d", ChunkMin, ChunkAvg, ChunkMax)
+	}
+
+	return nil
+}
+
+// Formats the dedup statistics to match the output format
+func toDedup() string {
+	dedupMutex.Lock()
+	defer dedupMutex.Unlock()
+
+	ratio := 0.0
+	if dedupUniqueBytes != 0 {
+		ratio = float64(dedupBytes) / float64(dedupUniqueBytes)
+	}
+
+	if jsonFormat() {
+		jsonString, _ := json.Marshal(struct {
+			ChunkMin     int
+			ChunkAvg     int
+			ChunkMax     int
+			Chunks       int64
+			UniqueChunks int64
+			Bytes        int64
+			UniqueBytes  int64
+			Ratio        float64
+		}{ChunkMin, ChunkAvg, ChunkMax, dedupChunks, dedupUniqueChunks, dedupBytes, dedupUniqueBytes, ratio})
+		return string(jsonString) + "\n"
+	}
+
+	prefix := footerPrefix()
+
+	var str strings.Builder
+	str.WriteString(fmt.Sprintf("%sdedup (fastcdc min %d avg %d max %d)\n", prefix, ChunkMin, ChunkAvg, ChunkMax))
+	str.WriteString(fmt.Sprintf("%s     chunks %d (%d unique)\n", prefix, dedupChunks, dedupUniqueChunks))
+	str.WriteString(fmt.Sprintf("%s      total %d bytes\n", prefix, dedupBytes))
+	str.WriteString(fmt.Sprintf("%s     unique %d bytes\n", prefix, dedupUniqueBytes))
+	str.WriteString(fmt.Sprintf("%s      ratio %.2f\n", prefix, ratio))
+
+	return str.String()
+}
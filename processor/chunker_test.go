@@ -0,0 +1,49 @@
+package processor
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestChunkerStreamMatchesMemory(t *testing.T) {
+	ChunkList = true
+	defer func() {
+		ChunkList = false
+	}()
+
+	content := make([]byte, 1000000)
+	rand.New(rand.NewSource(1)).Read(content)
+
+	expected := processChunks(&content)
+
+	c := newChunker()
+	for i := 0; i < len(content); i += 1000 {
+		_, _ = c.Write(content[i : i+1000])
+	}
+	actual := c.Close()
+
+	if len(expected) != len(actual) {
+		t.Fatalf("Expected %d chunks got %d", len(expected), len(actual))
+	}
+
+	var total int64
+	for i := range expected {
+		if expected[i] != actual[i] {
+			t.Errorf("Expected chunk %d to be %v got %v", i, expected[i], actual[i])
+		}
+
+		if expected[i].Length < int64(ChunkMin) && i != len(expected)-1 {
+			t.Errorf("Expected chunk %d to be at least %d bytes got %d", i, ChunkMin, expected[i].Length)
+		}
+
+		if expected[i].Length > int64(ChunkMax) {
+			t.Errorf("Expected chunk %d to be at most %d bytes got %d", i, ChunkMax, expected[i].Length)
+		}
+
+		total += expected[i].Length
+	}
+
+	if total != int64(len(content)) {
+		t.Errorf("Expected chunks to cover %d bytes got %d", len(content), total)
+	}
+}
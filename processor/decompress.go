@@ -0,0 +1,156 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// How much of the start of the content is read to determine the compression used
+const compressionProbeSize = 65536
+
+// Determine the compression used by looking at the magic bytes at the start of the content,
+// more is set if there is content after the sample which is not included in it
+func compressionType(sample []byte, more bool) string {
+	switch {
+	case bytes.HasPrefix(sample, []byte{0x1f, 0x8b}):
+		return "gzip"
+	case len(sample) >= 4 && bytes.HasPrefix(sample, []byte("BZh")) && sample[3] >= '1' && sample[3] <= '9':
+		return "bzip2"
+	case len(sample) >= 2 && zlibHeader(sample[0], sample[1]) && inflates(sample, more):
+		return "zlib"
+	}
+
+	return ""
+}
+
+// Deflate with a window of at most 32K, no preset dictionary which would be needed to
+// decompress it and a valid header checksum. One in a few hundred pairs of bytes pass
+// this including some plain text so it is never used on its own
+func zlibHeader(cmf byte, flg byte) bool {
+	return cmf&0x0f == 8 && cmf>>4 <= 7 && flg&0x20 == 0 && (uint16(cmf)<<8|uint16(flg))%31 == 0
+}
+
+// Checks that the sample decompresses as zlib all the way to its checksum or, when
+// there is more content after it, at least as far as the sample goes
+func inflates(sample []byte, more bool) bool {
+	d, err := zlib.NewReader(bytes.NewReader(sample))
+	if err != nil {
+		return false
+	}
+
+	_, err = io.Copy(ioutil.Discard, d)
+	return err == nil || (more && err == io.ErrUnexpectedEOF)
+}
+
+func decompressor(kind string, reader io.Reader) (io.Reader, error) {
+	switch kind {
+	case "gzip":
+		return gzip.NewReader(reader)
+	case "bzip2":
+		return bzip2.NewReader(reader), nil
+	case "zlib":
+		return zlib.NewReader(reader)
+	}
+
+	return nil, fmt.Errorf("unknown compression %s", kind)
+}
+
+// Name used to report the hashes of the decompressed content so it cannot be
+// confused with the hashes of the file itself
+func decompressedName(filename string, kind string) string {
+	return fmt.Sprintf("%s (%s decompressed)", filename, kind)
+}
+
+// Hashes the decompressed content of the reader along with the raw content, reading the
+// underlying data only once. The raw content is output if asked for or if the content could
+// not be decompressed so there is always a result for what was read
+func processCompressedStream(filename string, kind string, reader io.Reader, output chan Result) {
+	// The raw content is hashed as it is read and copied into a pipe
+	// which the decompressed content is read from at the same time
+	pr, pw := io.Pipe()
+	decompressed := make(chan error, 1)
+	var res Result
+
+	go func() {
+		// Always consume everything so the raw hashing is never blocked
+		defer io.Copy(ioutil.Discard, pr)
+
+		d, err := decompressor(kind, pr)
+		if err == nil {
+			res, err = processStream(decompressedName(filename, kind), d)
+		}
+		decompressed <- err
+	}()
+
+	raw, err := processStream(filename, io.TeeReader(reader, pw))
+	_ = pw.Close()
+	decompressErr := <-decompressed
+
+	if err != nil {
+		recordError(filename, fmt.Sprintf("Unable to process file %s with error %s", filename, err.Error()))
+		return
+	}
+
+	if decompressErr != nil {
+		if Verbose {
+			printVerbose(fmt.Sprintf("unable to decompress %s using the raw content: %s", filename, decompressErr.Error()))
+		}
+		output <- raw
+		return
+	}
+
+	if Decompress == "also" {
+		output <- raw
+	}
+	output <- res
+}
+
+// Checks if the already open file is compressed and if so hashes its decompressed
+// content returning false if the file is not compressed and should be processed
+// as normal. The sample is read at an offset so the file is left where it was
+func processDecompress(filename string, file *os.File, output chan Result) bool {
+	sample := make([]byte, compressionProbeSize)
+	n, err := file.ReadAt(sample, 0)
+	if err != nil && err != io.EOF {
+		return false
+	}
+
+	kind := compressionType(sample[:n], err == nil)
+	if kind == "" {
+		return false
+	}
+
+	if Debug {
+		printDebug(fmt.Sprintf("%s %s compressed using decompress", filename, kind))
+	}
+
+	processCompressedStream(filename, kind, meteredReader{file}, output)
+	return true
+}
+
+// Same as processStandardInput but checks if the content is compressed first
+func processDecompressStandardInput(output chan Result) {
+	reader := bufio.NewReaderSize(meteredReader{os.Stdin}, compressionProbeSize)
+	sample, err := reader.Peek(compressionProbeSize)
+
+	kind := compressionType(sample, err == nil)
+	if kind == "" {
+		res, err := processStream("stdin", reader)
+		if err != nil {
+			recordError("stdin", fmt.Sprintf("Unable to process stdin with error %s", err.Error()))
+		} else {
+			output <- res
+		}
+	} else {
+		processCompressedStream("stdin", kind, reader, output)
+	}
+
+	close(output)
+}
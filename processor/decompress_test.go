@@ -0,0 +1,166 @@
+package processor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// hello world\n compressed using bzip2 -9 as there is no bzip2 writer in the standard library
+var bzip2HelloWorld = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x4e, 0xec,
+	0xe8, 0x36, 0x00, 0x00, 0x02, 0x51, 0x80, 0x00, 0x10, 0x40, 0x00, 0x06,
+	0x44, 0x90, 0x80, 0x20, 0x00, 0x31, 0x06, 0x4c, 0x41, 0x01, 0xa7, 0xa9,
+	0xa5, 0x80, 0xbb, 0x94, 0x31, 0xf8, 0xbb, 0x92, 0x29, 0xc2, 0x84, 0x82,
+	0x77, 0x67, 0x41, 0xb0,
+}
+
+func compressed(kind string, content []byte) []byte {
+	var buf bytes.Buffer
+	switch kind {
+	case "gzip":
+		w := gzip.NewWriter(&buf)
+		_, _ = w.Write(content)
+		_ = w.Close()
+	case "zlib":
+		w := zlib.NewWriter(&buf)
+		_, _ = w.Write(content)
+		_ = w.Close()
+	}
+	return buf.Bytes()
+}
+
+func TestDecompress(t *testing.T) {
+	previous := Hash
+	Hash = []string{HashNames.MD5}
+	runErrors = []runIssue{}
+	defer func() {
+		Hash = previous
+		Decompress = ""
+		runErrors = []runIssue{}
+	}()
+
+	plain := []byte("hello world\n")
+	plainMD5 := "6f5902ac237024bdd0c176cb93063dc4"
+	gz := compressed("gzip", plain)
+	zl := compressed("zlib", plain)
+
+	cases := []struct {
+		name    string
+		content []byte
+		kind    string
+		md5     string
+	}{
+		{"gzip", gz, "gzip", plainMD5},
+		{"bzip2", bzip2HelloWorld, "bzip2", plainMD5},
+		{"zlib", zl, "zlib", plainMD5},
+		{"text x space", []byte("x marks the spot\n"), "", ""},
+		{"text Hj", []byte("Hjalmar was here\n"), "", ""},
+		{"text x caret", []byte("x^2 + y^2 = z^2 is the pythagorean theorem\n"), "", ""},
+		{"truncated gzip", gz[:len(gz)-6], "gzip", ""},
+		{"truncated bzip2", bzip2HelloWorld[:30], "bzip2", ""},
+		{"truncated zlib", zl[:len(zl)-3], "", ""},
+	}
+
+	for _, c := range cases {
+		kind := compressionType(c.content, false)
+		if kind != c.kind {
+			t.Errorf("Expected %q for %s got %q", c.kind, c.name, kind)
+		}
+		if kind == "" {
+			continue
+		}
+
+		// Anything which cannot be decompressed falls back to the raw content
+		Decompress = "only"
+		output := make(chan Result, 10)
+		processCompressedStream(c.name, kind, bytes.NewReader(c.content), output)
+		close(output)
+
+		if len(output) != 1 {
+			t.Errorf("Expected 1 result for %s got %d", c.name, len(output))
+			continue
+		}
+
+		res := <-output
+		if c.md5 != "" && (res.MD5 != c.md5 || res.File != decompressedName(c.name, kind)) {
+			t.Errorf("Expected decompressed %s for %s got %s %s", c.md5, c.name, res.File, res.MD5)
+		}
+		if c.md5 == "" && res.File != c.name {
+			t.Errorf("Expected raw content for %s got %s", c.name, res.File)
+		}
+	}
+
+	if errorCount() != 0 {
+		t.Errorf("Expected no errors got %d", errorCount())
+	}
+}
+
+func TestDecompressAlso(t *testing.T) {
+	previous := Hash
+	Hash = []string{HashNames.MD5}
+	Decompress = "also"
+	defer func() {
+		Hash = previous
+		Decompress = ""
+	}()
+
+	output := make(chan Result, 10)
+	processCompressedStream("file", "gzip", bytes.NewReader(compressed("gzip", []byte("hello world\n"))), output)
+	close(output)
+
+	if len(output) != 2 {
+		t.Fatalf("Expected 2 results got %d", len(output))
+	}
+
+	if res := <-output; res.File != "file" {
+		t.Errorf("Expected raw result first got %s", res.File)
+	}
+}
+
+func TestDecompressOpenFile(t *testing.T) {
+	previous := Hash
+	Hash = []string{HashNames.MD5}
+	Decompress = "only"
+	defer func() {
+		Hash = previous
+		Decompress = ""
+	}()
+
+	dir, err := ioutil.TempDir("", "hashit-decompress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	plain := filepath.Join(dir, "plain")
+	_ = ioutil.WriteFile(plain, []byte("hello world\n"), 0644)
+	gz := filepath.Join(dir, "plain.gz")
+	_ = ioutil.WriteFile(gz, compressed("gzip", []byte("hello world\n")), 0644)
+
+	// Content which is not compressed is left to be read from the start as normal
+	file, _ := os.Open(plain)
+	output := make(chan Result, 10)
+	if processDecompress(plain, file, output) {
+		t.Error("Expected plain file not to be decompressed")
+	}
+	if offset, _ := file.Seek(0, io.SeekCurrent); offset != 0 {
+		t.Errorf("Expected offset 0 got %d", offset)
+	}
+	_ = file.Close()
+
+	file, _ = os.Open(gz)
+	if !processDecompress(gz, file, output) {
+		t.Error("Expected gzip file to be decompressed")
+	}
+	_ = file.Close()
+
+	if res := <-output; res.MD5 != "6f5902ac237024bdd0c176cb93063dc4" {
+		t.Errorf("Expected 6f5902ac237024bdd0c176cb93063dc4 got %s", res.MD5)
+	}
+}
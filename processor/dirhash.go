@@ -0,0 +1,330 @@
+package processor
+
+import (
+	"archive/zip"
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Go module dirhash which is the h1: value stored in go.sum
+// See golang.org/x/mod/sumdb/dirhash for the reference implementation
+// which is reproduced here so it can run without the Go toolchain
+func goHash1(files []string, digests map[string]string) (string, error) {
+	sorted := make([]string, len(files))
+	copy(sorted, files)
+	sort.Strings(sorted)
+
+	d := sha256.New()
+	for _, f := range sorted {
+		if strings.Contains(f, "\n") {
+			return "", fmt.Errorf("dirhash: filenames with newlines are not supported: %q", f)
+		}
+		fmt.Fprintf(d, "%s  %s\n", digests[f], f)
+	}
+
+	return "h1:" + base64.StdEncoding.EncodeToString(d.Sum(nil)), nil
+}
+
+// Calculates the h1: hash for a directory where every file is named as
+// prefix/relative/path the same way the Go toolchain does for a module
+func goHashDir(dir string, prefix string) (string, error) {
+	dir = filepath.Clean(dir)
+
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !fi.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", dir)
+	}
+
+	paths, err := goDirFiles(dir)
+	if err != nil {
+		return "", err
+	}
+
+	digests, err := sha256Files(dir, paths)
+	if err != nil {
+		return "", err
+	}
+
+	files := []string{}
+	named := map[string]string{}
+	for file, digest := range digests {
+		rel := file
+		if dir != "." {
+			rel = strings.TrimPrefix(file, dir+string(os.PathSeparator))
+		}
+
+		name := filepath.ToSlash(filepath.Join(prefix, rel))
+		files = append(files, name)
+		named[name] = digest
+	}
+
+	return goHash1(files, named)
+}
+
+// Calculates the h1: hash for a module zip file using the names stored in the zip
+func goHashZip(zipfile string) (string, error) {
+	z, err := zip.OpenReader(zipfile)
+	if err != nil {
+		return "", err
+	}
+	defer z.Close()
+
+	files := []string{}
+	digests := map[string]string{}
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+
+		d := sha256.New()
+		_, err = io.Copy(d, r)
+		_ = r.Close()
+		if err != nil {
+			return "", err
+		}
+
+		files = append(files, f.Name)
+		digests[f.Name] = hex.EncodeToString(d.Sum(nil))
+	}
+
+	return goHash1(files, digests)
+}
+
+// Lists every file below the directory the same way dirhash.DirFiles does. None of
+// the walker options apply as anything which changed the files included would
+// produce a hash which no longer matches the one calculated by the Go toolchain
+func goDirFiles(dir string) ([]string, error) {
+	files := []string{}
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, file)
+		}
+		return nil
+	})
+
+	return files, err
+}
+
+// Calculates the SHA256 of every file returning a map of file to its digest, failing
+// if any of them cannot be read as the h1: hash would be wrong without it
+func sha256Files(dir string, files []string) (map[string]string, error) {
+	input := make(chan string, len(files))
+	for _, f := range files {
+		input <- f
+	}
+	close(input)
+
+	digests := map[string]string{}
+	var firstErr error
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	threads := ioThreads([]string{dir})
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range input {
+				digest, err := sha256File(f)
+
+				mutex.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				digests[f] = digest
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return digests, firstErr
+}
+
+func sha256File(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	d := sha256.New()
+	if _, err := io.Copy(d, meteredReader{file}); err != nil {
+		return "", fmt.Errorf("reading file %s: %s", filename, err.Error())
+	}
+
+	return hex.EncodeToString(d.Sum(nil)), nil
+}
+
+// Prints the h1: hash for every directory or zip supplied as an argument
+func processGoDirHash() bool {
+	var str strings.Builder
+	valid := true
+
+	if len(DirFilePaths) == 0 {
+		DirFilePaths = append(DirFilePaths, ".")
+	}
+
+	for _, f := range DirFilePaths {
+		fp := filepath.Clean(f)
+
+		var h string
+		var err error
+		if strings.HasSuffix(strings.ToLower(fp), ".zip") {
+			h, err = goHashZip(fp)
+		} else {
+			h, err = goHashDir(fp, GoDirHashPrefix)
+		}
+
+		if err != nil {
+			printError(fmt.Sprintf("unable to calculate dirhash for %s: %s", fp, err.Error()))
+			valid = false
+			continue
+		}
+
+		str.WriteString(fmt.Sprintf("%s  %s\n", h, fp))
+	}
+
+	writeOutput(str.String())
+	return valid
+}
+
+// Single line from a go.sum file
+type goSumEntry struct {
+	Module  string
+	Version string
+	Hash    string
+}
+
+// Reads go.sum ignoring the /go.mod entries as those never exist in a vendor tree
+func loadGoSum(filename string) ([]goSumEntry, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := []goSumEntry{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || strings.HasSuffix(fields[1], "/go.mod") {
+			continue
+		}
+
+		entries = append(entries, goSumEntry{
+			Module:  fields[0],
+			Version: fields[1],
+			Hash:    fields[2],
+		})
+	}
+
+	return entries, scanner.Err()
+}
+
+// Reads the module versions from vendor/modules.txt if it exists
+func loadVendorModules(vendor string) map[string]string {
+	modules := map[string]string{}
+
+	file, err := os.Open(filepath.Join(vendor, "modules.txt"))
+	if err != nil {
+		return modules
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 3 && fields[0] == "#" && !strings.HasPrefix(fields[1], "=>") {
+			modules[fields[1]] = fields[2]
+		}
+	}
+
+	return modules
+}
+
+// Checks every module in the vendor directory against the hashes in go.sum
+// Note that go mod vendor only copies the packages that are used so such
+// trees will only match when the whole module was vendored
+func processGoSumVerify() bool {
+	entries, err := loadGoSum(GoSumFile)
+	if err != nil {
+		printError(fmt.Sprintf("unable to load go.sum file: %s %s", GoSumFile, err.Error()))
+		exit(1)
+	}
+
+	vendor := "vendor"
+	if len(DirFilePaths) != 0 {
+		vendor = filepath.Clean(DirFilePaths[0])
+	}
+
+	modules := loadVendorModules(vendor)
+
+	// Group the expected hashes by module as go.sum can hold many versions
+	expected := map[string][]goSumEntry{}
+	order := []string{}
+	for _, e := range entries {
+		if version, ok := modules[e.Module]; ok && version != e.Version {
+			continue
+		}
+
+		if _, ok := expected[e.Module]; !ok {
+			order = append(order, e.Module)
+		}
+		expected[e.Module] = append(expected[e.Module], e)
+	}
+
+	var str strings.Builder
+	valid := true
+
+	for _, module := range order {
+		dir := filepath.Join(vendor, filepath.FromSlash(module))
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			if Verbose {
+				printVerbose(fmt.Sprintf("module not vendored: %s", module))
+			}
+			continue
+		}
+
+		status := "fail"
+		var last goSumEntry
+		for _, e := range expected[module] {
+			last = e
+			h, err := goHashDir(dir, e.Module+"@"+e.Version)
+			if err != nil {
+				printError(fmt.Sprintf("unable to calculate dirhash for %s: %s", dir, err.Error()))
+				break
+			}
+
+			if h == e.Hash {
+				status = "pass"
+				break
+			}
+		}
+
+		if status == "fail" {
+			valid = false
+		}
+
+		str.WriteString(fmt.Sprintf("%s %s %s %s\n", dir, last.Version, last.Hash, status))
+	}
+
+	writeOutput(str.String())
+	return valid
+}
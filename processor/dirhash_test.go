@@ -0,0 +1,132 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGoHash1(t *testing.T) {
+	files := []string{"mod@v1.0.0/b/c.txt", "mod@v1.0.0/a.txt"}
+	digests := map[string]string{
+		"mod@v1.0.0/a.txt":   "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		"mod@v1.0.0/b/c.txt": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+	}
+
+	res, err := goHash1(files, digests)
+
+	if err != nil {
+		t.Errorf("Expected no error got %s", err.Error())
+	}
+
+	if res != "h1:wlNudlAt/d2W4bzqnMyfapwZCIOTthLgkYj5burbvOw=" {
+		t.Errorf("Expected h1:wlNudlAt/d2W4bzqnMyfapwZCIOTthLgkYj5burbvOw= got %s", res)
+	}
+}
+
+func TestGoHash1Newline(t *testing.T) {
+	_, err := goHash1([]string{"bad\nname"}, map[string]string{})
+
+	if err == nil {
+		t.Error("Expected error for filename containing a newline")
+	}
+}
+
+func TestGoHashDirIgnoresWalkerOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hashit-dirhash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	contents := map[string]string{
+		"a.txt":          "hello",
+		".hidden":        "hidden",
+		".gitignore":     "a.txt\n",
+		"sub/deep/c.txt": "deep",
+	}
+
+	files := []string{}
+	digests := map[string]string{}
+	for name, content := range contents {
+		_ = os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		_ = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+
+		sum := sha256.Sum256([]byte(content))
+		files = append(files, "mod@v1.0.0/"+name)
+		digests["mod@v1.0.0/"+name] = hex.EncodeToString(sum[:])
+	}
+
+	expected, _ := goHash1(files, digests)
+
+	options := map[string]func() func(){
+		"no-hidden": func() func() {
+			Hidden = false
+			return func() { Hidden = true }
+		},
+		"max-depth": func() func() {
+			MaxDepth = 1
+			return func() { MaxDepth = -1 }
+		},
+		"ignore-files": func() func() {
+			IgnoreFiles = true
+			return func() { IgnoreFiles = false }
+		},
+		"symlinks": func() func() {
+			Symlinks = "skip"
+			return func() { Symlinks = "files" }
+		},
+		"one-file-system": func() func() {
+			OneFileSystem = true
+			return func() { OneFileSystem = false }
+		},
+		"exclude": func() func() {
+			Exclude = []string{"*.txt"}
+			_ = compileFilters()
+			return func() {
+				Exclude = []string{}
+				_ = compileFilters()
+			}
+		},
+		"min-size": func() func() {
+			MinSize = "5"
+			_ = parseFilters()
+			return func() {
+				MinSize = ""
+				_ = parseFilters()
+			}
+		},
+	}
+
+	for name, set := range options {
+		reset := set()
+		res, err := goHashDir(dir, "mod@v1.0.0")
+		reset()
+
+		if err != nil {
+			t.Errorf("Expected no error with %s got %s", name, err.Error())
+		} else if res != expected {
+			t.Errorf("Expected %s with %s got %s", expected, name, res)
+		}
+	}
+}
+
+func TestGoHashDirUnreadable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hashit-dirhash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	_ = ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0644)
+	if err := os.Symlink("missing", filepath.Join(dir, "broken")); err != nil {
+		t.Skip("symlinks not supported")
+	}
+
+	if res, err := goHashDir(dir, "mod@v1.0.0"); err == nil {
+		t.Errorf("Expected error for unreadable file got %s", res)
+	}
+}
@@ -3,22 +3,88 @@ package processor
 import (
 	"fmt"
 	"github.com/karrick/godirwalk"
+	"os"
+	"path/filepath"
 	"strings"
 )
 
 func walkDirectory(toWalk string, output chan string) {
+	walk(toWalk, output, true)
+}
+
+// Finds the same files as walkDirectory without recording anything about
+// them which is used to count the files before they are processed
+func scanDirectory(toWalk string, output chan string) {
+	walk(toWalk, output, false)
+}
+
+func walk(toWalk string, output chan string, record bool) {
+	filter := newWalkFilter(toWalk, record)
+
 	err := godirwalk.Walk(toWalk, &godirwalk.Options{
-		Unsorted: false, // We want the run to be deterministic
+		Unsorted:            false, // We want the run to be deterministic
+		FollowSymbolicLinks: true,  // Symlinks are followed only if the filter says to
 		Callback: func(root string, info *godirwalk.Dirent) error {
-			if !info.IsDir() {
+			mode := info.ModeType()
+			if info.IsSymlink() && root != toWalk {
+				follow, target := filter.symlink(root)
+				if !follow {
+					if Symlinks == "record" && !filter.skip(root, false) {
+						output <- root
+					}
+					if target.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				mode = target
+			}
+			isDir := mode.IsDir()
+
+			// Only files which are named explicitly are read if they might never end
+			if mode&specialMode != 0 {
+				if record {
+					reportSkipped(&skippedSpecial, root, specialKind(mode))
+				}
+				return nil
+			}
+
+			if filter.skip(root, isDir) {
+				if isDir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if !isDir {
 				output <- root
+				return nil
+			}
+
+			if !filter.enter(root) {
+				return filepath.SkipDir
+			}
+			if record && TreeHashEmptyDirs && root != toWalk {
+				names, err := godirwalk.ReadDirnames(root, nil)
+				if err == nil && len(names) == 0 {
+					recordEmptyDirectory(root)
+				}
 			}
 
 			return nil
 		},
+		PostChildrenCallback: func(root string, info *godirwalk.Dirent) error {
+			filter.leave(root)
+			return nil
+		},
 		ErrorCallback: func(osPathname string, err error) godirwalk.ErrorAction {
-			if Verbose {
-				printVerbose(fmt.Sprintf("error walking: %s %s", osPathname, err))
+			// Broken symlinks are reported by the worker when followed and ignored otherwise
+			if fi, lerr := os.Lstat(osPathname); lerr == nil && fi.Mode()&os.ModeSymlink != 0 {
+				return godirwalk.SkipNode
+			}
+
+			if record {
+				recordError(osPathname, fmt.Sprintf("error walking: %s %s", osPathname, err))
 			}
 			return godirwalk.SkipNode
 		},
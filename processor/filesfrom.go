@@ -0,0 +1,71 @@
+package processor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Opens the list of files to process from FilesFrom, or stdin if it is -
+func openFilesFrom() (io.ReadCloser, error) {
+	if FilesFrom == "-" {
+		return ioutil.NopCloser(os.Stdin), nil
+	}
+
+	file, err := os.Open(FilesFrom)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// Reads the list of files to process adding each to the queue as soon as it
+// is read so processing starts straight away
+func readFilesFrom(reader io.Reader, fileListQueue chan string) {
+	delimiter := byte('\n')
+	if NullDelimited {
+		delimiter = 0
+	}
+
+	buffered := bufio.NewReader(reader)
+	for {
+		line, err := buffered.ReadString(delimiter)
+		if len(line) != 0 && line[len(line)-1] == delimiter {
+			line = line[:len(line)-1]
+		}
+		// Lists created on Windows end lines with \r\n
+		if !NullDelimited && len(line) != 0 && line[len(line)-1] == '\r' {
+			line = line[:len(line)-1]
+		}
+
+		if line != "" {
+			fp := filepath.Clean(line)
+			fi, statErr := os.Stat(fp)
+
+			// Unlike arguments a missing file in a list should not stop everything else
+			if statErr != nil {
+				recordError(fp, fmt.Sprintf("file or directory issue: %s %s", fp, statErr.Error()))
+			} else if fi.IsDir() {
+				if Recursive {
+					isDir = true
+					walkDirectory(fp, fileListQueue)
+				} else {
+					reportSkipped(&skippedDirectories, fp, "directory with --no-recursive set")
+				}
+			} else {
+				fileListQueue <- fp
+			}
+		}
+
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			recordError(FilesFrom, fmt.Sprintf("unable to read files from: %s %s", FilesFrom, err.Error()))
+			return
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package processor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Reads the list returning every file queued in sorted order
+func filesFromQueue(t *testing.T) []string {
+	reader, err := openFilesFrom()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	queue := make(chan string, 10)
+	readFilesFrom(reader, queue)
+	close(queue)
+
+	var files []string
+	for f := range queue {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestFilesFromNullDelimited(t *testing.T) {
+	runErrors = []runIssue{}
+	defer func() {
+		FilesFrom = ""
+		NullDelimited = false
+		runErrors = []runIssue{}
+	}()
+
+	dir, err := ioutil.TempDir("", "hashit-filesfrom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A name containing a newline can only be listed when separated by NUL
+	newline := filepath.Join(dir, "new\nline")
+	plain := filepath.Join(dir, "plain")
+	_ = ioutil.WriteFile(newline, []byte("a"), 0644)
+	_ = ioutil.WriteFile(plain, []byte("b"), 0644)
+
+	FilesFrom = filepath.Join(dir, "list")
+	_ = ioutil.WriteFile(FilesFrom, []byte(newline+"\x00"+plain+"\x00"), 0644)
+	NullDelimited = true
+
+	files := filesFromQueue(t)
+	if strings.Join(files, "|") != newline+"|"+plain {
+		t.Errorf("Expected %s and %s got %v", newline, plain, files)
+	}
+	if errorCount() != 0 {
+		t.Errorf("Expected no errors got %d", errorCount())
+	}
+}
+
+func TestFilesFromStandardInput(t *testing.T) {
+	runErrors = []runIssue{}
+	defer func() {
+		FilesFrom = ""
+		runErrors = []runIssue{}
+	}()
+
+	dir, err := ioutil.TempDir("", "hashit-filesfrom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := filepath.Join(dir, "a")
+	_ = ioutil.WriteFile(a, []byte("a"), 0644)
+
+	// Lines ending in \r\n and missing files are both handled
+	list := filepath.Join(dir, "list")
+	_ = ioutil.WriteFile(list, []byte(a+"\r\n"+filepath.Join(dir, "missing")+"\n"), 0644)
+	stdin, _ := os.Open(list)
+
+	previous := os.Stdin
+	os.Stdin = stdin
+	defer func() {
+		os.Stdin = previous
+		_ = stdin.Close()
+	}()
+
+	FilesFrom = "-"
+	files := filesFromQueue(t)
+	if len(files) != 1 || files[0] != a {
+		t.Errorf("Expected %s got %v", a, files)
+	}
+	if errorCount() != 1 {
+		t.Errorf("Expected 1 error for the missing file got %d", errorCount())
+	}
+}
+
+func TestFilesFromMissingList(t *testing.T) {
+	defer func() {
+		FilesFrom = ""
+	}()
+
+	FilesFrom = filepath.Join(os.TempDir(), "hashit-missing-list")
+	if _, err := openFilesFrom(); err == nil {
+		t.Error("Expected error for a missing list")
+	}
+}
@@ -0,0 +1,120 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits parsed from MinSize and MaxSize, -1 is no limit
+var minSizeBytes int64 = -1
+var maxSizeBytes int64 = -1
+
+// Times parsed from NewerThan and OlderThan, zero is no limit
+var newerThanTime time.Time
+var olderThanTime time.Time
+
+// Parses the size and time filters so any mistakes are reported before processing starts
+func parseFilters() error {
+	minSizeBytes, maxSizeBytes = -1, -1
+	newerThanTime, olderThanTime = time.Time{}, time.Time{}
+
+	var err error
+	if MinSize != "" {
+		if minSizeBytes, err = parseSize(MinSize); err != nil {
+			return fmt.Errorf("min-size %s", err.Error())
+		}
+	}
+	if MaxSize != "" {
+		if maxSizeBytes, err = parseSize(MaxSize); err != nil {
+			return fmt.Errorf("max-size %s", err.Error())
+		}
+	}
+
+	now := time.Now()
+	if NewerThan != "" {
+		if newerThanTime, err = parseTime(NewerThan, now); err != nil {
+			return fmt.Errorf("newer-than %s", err.Error())
+		}
+	}
+	if OlderThan != "" {
+		if olderThanTime, err = parseTime(OlderThan, now); err != nil {
+			return fmt.Errorf("older-than %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Timestamps accepted by parseTime, those without a zone are in local time
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// Parses either a duration before now such as 24h, 90m or 7d or a timestamp such as 2018-06-01
+func parseTime(value string, now time.Time) (time.Time, error) {
+	s := strings.TrimSpace(value)
+
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.ParseFloat(strings.TrimSuffix(s, "d"), 64)
+		if err == nil && days >= 0 {
+			return now.Add(-time.Duration(days * float64(24*time.Hour))), nil
+		}
+	}
+
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid duration or timestamp %s", value)
+}
+
+// Checks the depth, hidden, size and time filters returning true if the path should be skipped
+func skipAttributes(path string, rel string, isDir bool) bool {
+	if !Hidden && strings.HasPrefix(filepath.Base(path), ".") {
+		return true
+	}
+
+	if MaxDepth >= 0 {
+		depth := strings.Count(rel, "/") + 1
+		if depth > MaxDepth || (isDir && depth >= MaxDepth) {
+			return true
+		}
+	}
+
+	if isDir || (minSizeBytes < 0 && maxSizeBytes < 0 && newerThanTime.IsZero() && olderThanTime.IsZero()) {
+		return false
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		// Let the worker report whatever is wrong with it
+		return false
+	}
+
+	switch {
+	case minSizeBytes >= 0 && fi.Size() < minSizeBytes:
+		return true
+	case maxSizeBytes >= 0 && fi.Size() > maxSizeBytes:
+		return true
+	case !newerThanTime.IsZero() && !fi.ModTime().After(newerThanTime):
+		return true
+	case !olderThanTime.IsZero() && !fi.ModTime().Before(olderThanTime):
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,31 @@
+package processor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2018, 6, 10, 12, 0, 0, 0, time.UTC)
+
+	cases := map[string]time.Time{
+		"24h":                  now.Add(-24 * time.Hour),
+		"90m":                  now.Add(-90 * time.Minute),
+		"7d":                   now.Add(-7 * 24 * time.Hour),
+		"2018-06-01T10:00:00Z": time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC),
+		"2018-06-01":           time.Date(2018, 6, 1, 0, 0, 0, 0, time.Local),
+	}
+
+	for value, expected := range cases {
+		res, err := parseTime(value, now)
+		if err != nil {
+			t.Errorf("Expected no error for %s got %s", value, err.Error())
+		} else if !res.Equal(expected) {
+			t.Errorf("Expected %s for %s got %s", expected, value, res)
+		}
+	}
+
+	if _, err := parseTime("yesterday", now); err == nil {
+		t.Error("Expected error for yesterday")
+	}
+}
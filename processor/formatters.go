@@ -1,6 +1,7 @@
 package processor
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -55,6 +56,8 @@ func fileSummarize(input chan Result) (string, bool) {
 	switch {
 	case strings.ToLower(Format) == "json":
 		return toJSON(input), true
+	case strings.ToLower(Format) == "ndjson":
+		return toNDJSON(input), true
 	case strings.ToLower(Format) == "hashdeep":
 		return toHashDeep(input), true
 	case strings.ToLower(Format) == "sum": // Similar to md5sum sha1sum output format
@@ -64,6 +67,107 @@ func fileSummarize(input chan Result) (string, bool) {
 	return toText(input)
 }
 
+// Something calculated over the whole run which is printed after the results
+type runFooter struct {
+	key     string // Key of the footer in the json object
+	content string
+}
+
+// Checks if the json results are the first key of an object holding the
+// footers as well so the output is a single json document
+func jsonWrapped() bool {
+	return strings.ToLower(Format) == "json" && AuditFile == "" && !TreeHashOnly && (TreeHash || Similar > 0 || Dedup || Summary)
+}
+
+// Adds the footers after the results keeping them on their own lines
+// and separated from each other in the same way each format separates results
+func appendFooters(result string, footers []runFooter) string {
+	if len(footers) == 0 {
+		return result
+	}
+
+	if strings.ToLower(Format) == "json" {
+		return appendJSONFooters(result, footers)
+	}
+
+	separator := ""
+	if strings.ToLower(Format) == "text" {
+		separator = "\n"
+	}
+
+	for i, f := range footers {
+		if i != 0 || !TreeHashOnly {
+			result += separator
+		}
+		result += f.content
+	}
+
+	return result
+}
+
+// The json format is a single document so each footer is a key of the object
+// which holds the results, or the audit, alongside them
+func appendJSONFooters(result string, footers []runFooter) string {
+	if TreeHashOnly && len(footers) == 1 {
+		return footers[0].content
+	}
+
+	var str strings.Builder
+	switch {
+	case AuditFile != "":
+		str.WriteString(`{"Audit":` + strings.TrimSpace(result) + ",")
+	case TreeHashOnly:
+		str.WriteString("{")
+	default:
+		// The object was opened with the results as its first key by toJSON
+		str.WriteString(result + ",")
+	}
+
+	// Paths which failed or were skipped are kept apart from the results so every
+	// result is the same type of object, an audit lists them as problems already
+	if records := issueRecords(); len(records) != 0 && AuditFile == "" {
+		str.WriteString(`"Issues":[` + string(bytes.Join(records, []byte(","))) + "],")
+	}
+
+	for i, f := range footers {
+		if i != 0 {
+			str.WriteString(",")
+		}
+		str.WriteString(`"` + f.key + `":` + strings.TrimSpace(f.content))
+	}
+	str.WriteString("}\n")
+
+	return str.String()
+}
+
+// Marks the lines of a footer as comments in the formats which are read back in
+// by other tools such as md5sum -c so they are not mistaken for results
+func footerPrefix() string {
+	switch strings.ToLower(Format) {
+	case "hashdeep", "sum":
+		return "## "
+	}
+	return ""
+}
+
+// Formats that only have room for the name mark symlinks the same way ls does in a
+// comment before the hashes so the name still works with md5sum -c and audits
+func symlinkComment(res Result) string {
+	if res.Symlink != "" {
+		return "## symlink " + res.File + " -> " + res.Symlink + "\n"
+	}
+	return ""
+}
+
+// Marks a hard linked file with the path which was hashed for it in a comment so
+// it is clear the hashes were reused rather than calculated
+func hardlinkComment(res Result) string {
+	if res.HardlinkOf != "" {
+		return "## hardlink " + res.File + " -> " + res.HardlinkOf + "\n"
+	}
+	return ""
+}
+
 // Mimics how md5sum sha1sum etc... work
 func toSum(input chan Result) string {
 	var str strings.Builder
@@ -76,6 +180,8 @@ func toSum(input chan Result) string {
 		} else {
 			first = false
 		}
+		str.WriteString(hardlinkComment(res))
+		str.WriteString(symlinkComment(res))
 
 		if hasHash(HashNames.MD4) {
 			str.WriteString(res.MD4 + "  " + res.File + "\n")
@@ -110,6 +216,15 @@ func toSum(input chan Result) string {
 		if hasHash(HashNames.Sha3512) {
 			str.WriteString(res.Sha3512 + "  " + res.File + "\n")
 		}
+		if hasHash(HashNames.AHash) && res.AHash != "" {
+			str.WriteString(res.AHash + "  " + res.File + "\n")
+		}
+		if hasHash(HashNames.DHash) && res.DHash != "" {
+			str.WriteString(res.DHash + "  " + res.File + "\n")
+		}
+		if hasHash(HashNames.PHash) && res.PHash != "" {
+			str.WriteString(res.PHash + "  " + res.File + "\n")
+		}
 
 		if NoStream == false && FileOutput == "" {
 			fmt.Print(str.String())
@@ -167,6 +282,24 @@ func toText(input chan Result) (string, bool) {
 		if hasHash(HashNames.Sha3512) {
 			str.WriteString("   SHA3-512 " + res.Sha3512 + "\n")
 		}
+		if hasHash(HashNames.AHash) && res.AHash != "" {
+			str.WriteString("      aHash " + res.AHash + "\n")
+		}
+		if hasHash(HashNames.DHash) && res.DHash != "" {
+			str.WriteString("      dHash " + res.DHash + "\n")
+		}
+		if hasHash(HashNames.PHash) && res.PHash != "" {
+			str.WriteString("      pHash " + res.PHash + "\n")
+		}
+		for _, c := range res.Chunks {
+			str.WriteString(fmt.Sprintf("      chunk %d %d %s\n", c.Offset, c.Length, c.SHA256))
+		}
+		if res.HardlinkOf != "" {
+			str.WriteString("   hardlink " + res.HardlinkOf + "\n")
+		}
+		if res.Symlink != "" {
+			str.WriteString("    symlink " + res.Symlink + "\n")
+		}
 
 		if FileAudit {
 			valid = auditFile(&str, res)
@@ -287,14 +420,58 @@ func findByHashes(res Result) string {
 	return ""
 }
 
+// Writes each result as it arrives rather than holding them all in memory
+// producing exactly the same array as marshalling them all at once
 func toJSON(input chan Result) string {
-	results := []Result{}
+	var str strings.Builder
+	if jsonWrapped() {
+		str.WriteString(`{"Results":`)
+	}
+	str.WriteString("[")
+
+	first := true
+	for res := range input {
+		if first == false {
+			str.WriteString(",")
+		} else {
+			first = false
+		}
+
+		jsonString, _ := json.Marshal(res)
+		str.Write(jsonString)
+
+		if NoStream == false && FileOutput == "" {
+			fmt.Print(str.String())
+			str.Reset()
+		}
+	}
+
+	str.WriteString("]")
+	return str.String()
+}
+
+// Writes each result as a json object on its own line so the output can be
+// processed line by line while it is still running
+func toNDJSON(input chan Result) string {
+	var str strings.Builder
+
 	for res := range input {
-		results = append(results, res)
+		jsonString, _ := json.Marshal(res)
+		str.Write(jsonString)
+		str.WriteString("\n")
+
+		if NoStream == false && FileOutput == "" {
+			fmt.Print(str.String())
+			str.Reset()
+		}
+	}
+
+	for _, record := range issueRecords() {
+		str.Write(record)
+		str.WriteString("\n")
 	}
 
-	jsonString, _ := json.Marshal(results)
-	return string(jsonString)
+	return str.String()
 }
 
 func toHashDeep(input chan Result) string {
@@ -311,12 +488,21 @@ func toHashDeep(input chan Result) string {
 
 	str.WriteString("%%%% HASHDEEP-1.0\n")
 	str.WriteString("%%%% size,md5,sha256,filename\n")
-	str.WriteString(fmt.Sprintf("## Invoked from: %s\n", pwd))
-	str.WriteString(fmt.Sprintf("## $ %s\n", strings.Join(os.Args, " ")))
+	if !Reproducible {
+		str.WriteString(fmt.Sprintf("## Invoked from: %s\n", pwd))
+		str.WriteString(fmt.Sprintf("## $ %s\n", strings.Join(os.Args, " ")))
+	}
 	str.WriteString("##\n")
 
 	for res := range input {
+		str.WriteString(hardlinkComment(res))
+		str.WriteString(symlinkComment(res))
 		str.WriteString(fmt.Sprintf("%d,%s,%s,%s\n", res.Bytes, res.MD5, res.SHA256, res.File))
+
+		if NoStream == false && FileOutput == "" {
+			fmt.Print(str.String())
+			str.Reset()
+		}
 	}
 
 	return str.String()
@@ -334,4 +520,7 @@ func printHashes() {
 	fmt.Println(fmt.Sprintf("   SHA3-256 (%s)", HashNames.Sha3256))
 	fmt.Println(fmt.Sprintf("   SHA3-384 (%s)", HashNames.Sha3384))
 	fmt.Println(fmt.Sprintf("   SHA3-512 (%s)", HashNames.Sha3512))
+	fmt.Println(fmt.Sprintf("      aHash (%s) images only", HashNames.AHash))
+	fmt.Println(fmt.Sprintf("      dHash (%s) images only", HashNames.DHash))
+	fmt.Println(fmt.Sprintf("      pHash (%s) images only", HashNames.PHash))
 }
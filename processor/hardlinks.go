@@ -0,0 +1,66 @@
+package processor
+
+import (
+	"os"
+	"sync"
+)
+
+// Tracks an inode with more than one link so that it is only hashed once
+type hardlinkEntry struct {
+	first  string
+	done   chan bool
+	result Result
+	ok     bool
+}
+
+var hardlinkEntries = map[[2]uint64]*hardlinkEntry{}
+var hardlinkMutex = sync.Mutex{}
+
+// Returns the entry for the inode of the file if it has more than one link
+// and if this is the first path seen for it, in which case it must be finished
+// once hashed so any other path waiting on the same inode can use the result
+func hardlinkClaim(filename string, fi os.FileInfo) (*hardlinkEntry, bool) {
+	if !Hardlinks || fileLinks(fi) < 2 {
+		return nil, false
+	}
+
+	identity := fileIdentity(fi)
+	key := [2]uint64{identity.Device, identity.Inode}
+
+	hardlinkMutex.Lock()
+	defer hardlinkMutex.Unlock()
+
+	entry, ok := hardlinkEntries[key]
+	if !ok {
+		entry = &hardlinkEntry{first: filename, done: make(chan bool)}
+		hardlinkEntries[key] = entry
+		return entry, true
+	}
+
+	return entry, false
+}
+
+// Records the result for the inode releasing anything waiting on it
+func (e *hardlinkEntry) finish(result Result, ok bool) {
+	if e == nil {
+		return
+	}
+
+	e.result = result
+	e.ok = ok
+	close(e.done)
+}
+
+// Waits for the first path of the inode to be hashed returning its result
+// for this path, if the first path failed this path is hashed instead
+func (e *hardlinkEntry) wait(filename string) (Result, bool) {
+	<-e.done
+	if !e.ok {
+		return Result{}, false
+	}
+
+	result := e.result
+	result.File = filename
+	result.HardlinkOf = e.first
+	return result, true
+}
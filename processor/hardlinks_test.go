@@ -0,0 +1,33 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHardlinkSumComment(t *testing.T) {
+	dir := processTestDir(t)
+	defer os.RemoveAll(dir)
+	if err := os.Link(filepath.Join(dir, "a"), filepath.Join(dir, "linked")); err != nil {
+		t.Skip("hard links not supported")
+	}
+	defer func() {
+		Hardlinks = false
+		hardlinkEntries = map[[2]uint64]*hardlinkEntry{}
+	}()
+
+	Hardlinks = true
+	Hash = []string{HashNames.MD5}
+	Format = "sum"
+	output := processOutput(t, dir)
+
+	// Either path can be hashed first but both must have the hashes with the other marked
+	if strings.Count(output, "## hardlink ") != 1 {
+		t.Errorf("Expected one hardlink comment got %s", output)
+	}
+	if strings.Count(output, "60b725f10c9c85c70d97880dfe8191b3  ") != 2 {
+		t.Errorf("Expected the hash of both paths got %s", output)
+	}
+}
@@ -0,0 +1,27 @@
+package processor
+
+import (
+	"os"
+	"syscall"
+)
+
+// Identifies a file by device, inode, size and modification and change times
+func fileIdentity(fi os.FileInfo) cacheIdentity {
+	identity := cacheIdentity{Size: fi.Size(), MtimeNs: fi.ModTime().UnixNano()}
+
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		identity.Device = uint64(st.Dev)
+		identity.Inode = uint64(st.Ino)
+		identity.CtimeNs = st.Ctim.Nano()
+	}
+
+	return identity
+}
+
+// Number of hard links to the file
+func fileLinks(fi os.FileInfo) uint64 {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Nlink)
+	}
+	return 1
+}
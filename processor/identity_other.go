@@ -0,0 +1,18 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package processor
+
+import (
+	"os"
+)
+
+// Only the size and modification time are portable so they are all that is used elsewhere
+func fileIdentity(fi os.FileInfo) cacheIdentity {
+	return cacheIdentity{Size: fi.Size(), MtimeNs: fi.ModTime().UnixNano()}
+}
+
+// Hard links cannot be detected without the device and inode so every file is treated as unique
+func fileLinks(fi os.FileInfo) uint64 {
+	return 1
+}
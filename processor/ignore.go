@@ -0,0 +1,315 @@
+package processor
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Files read from every directory walked when IgnoreFiles is set, rules in later
+// files and deeper directories take priority over earlier ones
+var ignoreFileNames = []string{".gitignore", ".ignore", ".hashitignore"}
+
+// A single glob compiled to a regular expression matched against a slash separated relative path
+type ignoreRule struct {
+	pattern *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	return r.pattern.MatchString(rel)
+}
+
+var includeRules = []ignoreRule{}
+var excludeRules = []ignoreRule{}
+
+// Compiles the include and exclude globs so any mistakes are reported before processing starts
+func compileFilters() error {
+	includeRules = includeRules[:0]
+	excludeRules = excludeRules[:0]
+
+	for _, p := range Include {
+		rule, err := compileGlob(p)
+		if err != nil {
+			return fmt.Errorf("invalid include pattern %s: %s", p, err.Error())
+		}
+		includeRules = append(includeRules, rule)
+	}
+
+	for _, p := range Exclude {
+		rule, err := compileGlob(p)
+		if err != nil {
+			return fmt.Errorf("invalid exclude pattern %s: %s", p, err.Error())
+		}
+		excludeRules = append(excludeRules, rule)
+	}
+
+	return nil
+}
+
+// Compiles a glob using gitignore rules, a trailing / only matches directories, a pattern
+// containing a / is anchored to where it is relative to otherwise it matches the name at any depth
+func compileGlob(glob string) (ignoreRule, error) {
+	rule := ignoreRule{}
+	if strings.HasSuffix(glob, "/") {
+		rule.dirOnly = true
+		glob = strings.TrimRight(glob, "/")
+	}
+
+	expr := "^(?:.*/)?"
+	if strings.Contains(glob, "/") {
+		expr = "^"
+		glob = strings.TrimPrefix(glob, "/")
+	}
+
+	pattern, err := regexp.Compile(expr + globRegexp(glob) + "$")
+	if err != nil {
+		return rule, err
+	}
+
+	rule.pattern = pattern
+	return rule, nil
+}
+
+// Converts a glob into a regular expression where * and ? never match a / and a
+// ** path segment matches any number of directories
+func globRegexp(glob string) string {
+	var re strings.Builder
+
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			segmentStart := i == 0 || glob[i-1] == '/'
+			if segmentStart && i+1 < len(glob) && glob[i+1] == '*' && (i+2 == len(glob) || glob[i+2] == '/') {
+				if i+2 == len(glob) {
+					re.WriteString(".*")
+					i++
+				} else {
+					re.WriteString("(?:.*/)?")
+					i += 2
+				}
+			} else {
+				re.WriteString("[^/]*")
+			}
+		case '?':
+			re.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end <= 0 {
+				re.WriteString(`\[`)
+				continue
+			}
+
+			class := glob[i+1 : i+1+end]
+			if class[0] == '!' {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				re.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return re.String()
+}
+
+// Reads the rules from an ignore file skipping blank lines, comments and any
+// pattern which cannot be compiled
+func loadIgnoreFile(filename string) []ignoreRule {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	rules := []ignoreRule{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		// Trailing spaces are ignored unless escaped
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+			line = line[:len(line)-1]
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		negate := false
+		if strings.HasPrefix(line, "!") {
+			negate = true
+			line = line[1:]
+		}
+
+		rule, err := compileGlob(line)
+		if err != nil {
+			if Verbose {
+				printVerbose(fmt.Sprintf("invalid pattern in %s: %s %s", filename, line, err.Error()))
+			}
+			continue
+		}
+
+		rule.negate = negate
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// Decides what is skipped while walking a single directory tree keeping the
+// ignore rules for every directory between the root and the current path
+type walkFilter struct {
+	root    string
+	record  bool
+	ignores map[string][]ignoreRule
+	active  []activeDirectory
+}
+
+// Directory currently being walked
+type activeDirectory struct {
+	path string
+	info os.FileInfo
+}
+
+func newWalkFilter(root string, record bool) *walkFilter {
+	return &walkFilter{
+		root:    root,
+		record:  record,
+		ignores: map[string][]ignoreRule{},
+	}
+}
+
+func (w *walkFilter) relative(path string) string {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Reads the ignore files in a directory once it is known it will be walked remembering
+// it so loops back to it can be found, returning false if it is on a filesystem to skip
+func (w *walkFilter) enter(path string) bool {
+	fi, _ := os.Stat(path)
+	if fi != nil && w.crossesFilesystem(path, fi) {
+		return false
+	}
+	w.active = append(w.active, activeDirectory{path, fi})
+
+	if !IgnoreFiles {
+		return true
+	}
+
+	rules := []ignoreRule{}
+	for _, name := range ignoreFileNames {
+		rules = append(rules, loadIgnoreFile(filepath.Join(path, name))...)
+	}
+
+	if len(rules) != 0 {
+		w.ignores[w.relative(path)] = rules
+	}
+
+	return true
+}
+
+// Drops the rules for a directory once everything inside it has been walked
+func (w *walkFilter) leave(path string) {
+	// Directories which could not be read are never left so any below this one are dropped as well
+	for i := len(w.active) - 1; i >= 0; i-- {
+		if w.active[i].path == path {
+			w.active = w.active[:i]
+			break
+		}
+	}
+	delete(w.ignores, w.relative(path))
+}
+
+// Checks the path against the include and exclude globs and any ignore files
+// in the directories above it, a skipped directory is never walked
+func (w *walkFilter) skip(path string, isDir bool) bool {
+	rel := w.relative(path)
+	if rel == "." {
+		return false
+	}
+
+	if IgnoreFiles && isDir && filepath.Base(path) == ".git" {
+		return true
+	}
+
+	if skipAttributes(path, rel, isDir) {
+		if Debug {
+			printDebug(fmt.Sprintf("skipping %s filtered", path))
+		}
+		return true
+	}
+
+	for _, rule := range excludeRules {
+		if rule.match(rel, isDir) {
+			if Debug {
+				printDebug(fmt.Sprintf("skipping %s matched exclude", path))
+			}
+			return true
+		}
+	}
+
+	if !isDir && len(includeRules) != 0 {
+		included := false
+		for _, rule := range includeRules {
+			if rule.match(rel, false) {
+				included = true
+				break
+			}
+		}
+
+		if !included {
+			return true
+		}
+	}
+
+	if len(w.ignores) == 0 {
+		return false
+	}
+
+	// Rules are checked from the root down so that the last match wins
+	ignored := false
+	parts := strings.Split(rel, "/")
+	for i := range parts {
+		dir := "."
+		if i != 0 {
+			dir = strings.Join(parts[:i], "/")
+		}
+
+		rules, ok := w.ignores[dir]
+		if !ok {
+			continue
+		}
+
+		sub := strings.Join(parts[i:], "/")
+		for _, rule := range rules {
+			if rule.match(sub, isDir) {
+				ignored = !rule.negate
+			}
+		}
+	}
+
+	if ignored && Debug {
+		printDebug(fmt.Sprintf("skipping %s matched ignore file", path))
+	}
+
+	return ignored
+}
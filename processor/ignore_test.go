@@ -0,0 +1,43 @@
+package processor
+
+import (
+	"testing"
+)
+
+func TestCompileGlob(t *testing.T) {
+	cases := []struct {
+		glob  string
+		path  string
+		isDir bool
+		match bool
+	}{
+		{"*.log", "a.log", false, true},
+		{"*.log", "a/b/c.log", false, true},
+		{"*.log", "a/b.log/c", false, false},
+		{"/*.log", "a/b.log", false, false},
+		{"a/*.go", "a/b.go", false, true},
+		{"a/*.go", "a/b/c.go", false, false},
+		{"a/**/*.go", "a/b.go", false, true},
+		{"a/**/*.go", "a/b/c/d.go", false, true},
+		{"**/vendor", "x/vendor", true, true},
+		{"a/**", "a/b/c", false, true},
+		{"a/**", "a", true, false},
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"file?.[ch]", "file1.c", false, true},
+		{"file?.[!ch]", "file1.c", false, false},
+		{`\#note`, "#note", false, true},
+	}
+
+	for _, c := range cases {
+		rule, err := compileGlob(c.glob)
+		if err != nil {
+			t.Errorf("Expected no error for %s got %s", c.glob, err.Error())
+			continue
+		}
+
+		if rule.match(c.path, c.isDir) != c.match {
+			t.Errorf("Expected %s matching %s to be %t", c.glob, c.path, c.match)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// A single line in the journal recording every result for a file once it has finished
+// along with the hashes calculated and what the file looked like when it was read
+type journalRecord struct {
+	Path    string
+	Hashes  []string
+	Size    int64
+	MtimeNs int64
+	Results []Result
+}
+
+var journalFile *os.File
+var journalSynced time.Time
+
+// Records from a previous run loaded when resuming
+var journalRecords = map[string]journalRecord{}
+
+// Opens the journal, when resuming the previous journal is loaded and
+// appended to otherwise any existing journal is replaced
+func openJournal() {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	var valid int64
+
+	if Resume {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+
+		var err error
+		valid, err = loadJournal()
+		if err != nil && !os.IsNotExist(err) {
+			printError(fmt.Sprintf("unable to load journal: %s %s", Journal, err.Error()))
+			exit(1)
+		}
+
+		if Verbose {
+			printVerbose(fmt.Sprintf("resuming with %d finished files from journal", len(journalRecords)))
+		}
+	}
+
+	file, err := os.OpenFile(Journal, flags, 0600)
+	if err != nil {
+		printError(fmt.Sprintf("unable to open journal: %s %s", Journal, err.Error()))
+		exit(1)
+	}
+
+	// Drop anything after the last complete record, usually a line cut off
+	// when the previous run died, so new records start on their own line
+	if Resume {
+		if err := file.Truncate(valid); err != nil {
+			printError(fmt.Sprintf("unable to truncate journal: %s %s", Journal, err.Error()))
+			exit(1)
+		}
+	}
+
+	journalFile = file
+	journalSynced = time.Now()
+}
+
+// Loads every complete record from the journal returning the offset just
+// after the last one which was read successfully
+func loadJournal() (int64, error) {
+	file, err := os.Open(Journal)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var offset int64
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return offset, nil
+		}
+		if err != nil {
+			return offset, err
+		}
+
+		var record journalRecord
+		if err := json.Unmarshal(bytes.TrimSpace(line), &record); err != nil {
+			if Verbose {
+				printVerbose(fmt.Sprintf("ignoring invalid journal record at offset %d", offset))
+			}
+			return offset, nil
+		}
+
+		journalRecords[record.Path] = record
+		offset += int64(len(line))
+	}
+}
+
+// Names of every hash calculated as a record missing any of them cannot be reused
+func journalHashes() []string {
+	names := []string{}
+	for _, h := range cacheHashes() {
+		if calculateHash(h.name) {
+			names = append(names, h.name)
+		}
+	}
+	return names
+}
+
+// Returns the results for the file if it finished in a previous run with every
+// hash requested and has not changed since, otherwise it is processed again
+func journalLookup(path string) ([]Result, bool) {
+	record, ok := journalRecords[path]
+	if !ok {
+		return nil, false
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil || fi.Size() != record.Size || fi.ModTime().UnixNano() != record.MtimeNs {
+		if Verbose {
+			printVerbose(fmt.Sprintf("%s changed since it was journaled processing again", path))
+		}
+		return nil, false
+	}
+
+	recorded := map[string]bool{}
+	for _, name := range record.Hashes {
+		recorded[name] = true
+	}
+	for _, name := range journalHashes() {
+		if !recorded[name] {
+			if Verbose {
+				printVerbose(fmt.Sprintf("%s was journaled without %s processing again", path, name))
+			}
+			return nil, false
+		}
+	}
+
+	return record.Results, true
+}
+
+// Records that the file has finished along with its results. Files which
+// produced no results, usually because they could not be read, are left out
+// so they are tried again when resuming
+func journalWrite(path string, results []Result) {
+	if journalFile == nil || len(results) == 0 {
+		return
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	line, err := json.Marshal(journalRecord{
+		Path:    path,
+		Hashes:  journalHashes(),
+		Size:    fi.Size(),
+		MtimeNs: fi.ModTime().UnixNano(),
+		Results: results,
+	})
+	if err != nil {
+		printError(fmt.Sprintf("unable to write journal record for %s: %s", path, err.Error()))
+		return
+	}
+
+	if _, err := journalFile.Write(append(line, '\n')); err != nil {
+		printError(fmt.Sprintf("unable to write journal record for %s: %s", path, err.Error()))
+		return
+	}
+
+	// Syncing every record would be slow so do it at most once a second
+	if time.Since(journalSynced) > time.Second {
+		_ = journalFile.Sync()
+		journalSynced = time.Now()
+	}
+}
+
+func closeJournal() {
+	if journalFile == nil {
+		return
+	}
+
+	_ = journalFile.Sync()
+	_ = journalFile.Close()
+}
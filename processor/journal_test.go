@@ -0,0 +1,114 @@
+package processor
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLoadJournalTruncated(t *testing.T) {
+	file, _ := ioutil.TempFile("", "hashit")
+	defer os.Remove(file.Name())
+
+	complete := "{\"Path\":\"a\",\"Results\":[{\"File\":\"a\",\"MD5\":\"1\"}]}\n"
+	_, _ = file.WriteString(complete + "{\"Path\":\"b\",\"Resu")
+	_ = file.Close()
+
+	previous := Journal
+	Journal = file.Name()
+	defer func() {
+		Journal = previous
+		journalRecords = map[string]journalRecord{}
+	}()
+
+	offset, err := loadJournal()
+	if err != nil {
+		t.Errorf("Expected no error got %s", err.Error())
+	}
+
+	if offset != int64(len(complete)) {
+		t.Errorf("Expected offset %d got %d", len(complete), offset)
+	}
+
+	if _, ok := journalRecords["a"]; !ok {
+		t.Error("Expected a to be in the journal")
+	}
+
+	if _, ok := journalRecords["b"]; ok {
+		t.Error("Expected b to not be in the journal")
+	}
+}
+
+// Journals a single file with the hashes requested then loads it back as a resumed run would
+func journalAndLoad(t *testing.T, path string, hashes []string) {
+	Hash = hashes
+	Resume = false
+	journalRecords = map[string]journalRecord{}
+
+	openJournal()
+	journalWrite(path, []Result{{File: path, MD5: "5d41402abc4b2a76b9719d911017c592"}})
+	closeJournal()
+	journalFile = nil
+
+	if _, err := loadJournal(); err != nil {
+		t.Fatalf("Expected no error got %s", err.Error())
+	}
+}
+
+func TestJournalResumeChangedHashes(t *testing.T) {
+	journal, _ := ioutil.TempFile("", "hashit")
+	file, _ := ioutil.TempFile("", "hashit")
+	defer os.Remove(journal.Name())
+	defer os.Remove(file.Name())
+	_, _ = file.WriteString("hello")
+	_ = file.Close()
+
+	previous, previousJournal := Hash, Journal
+	Journal = journal.Name()
+	defer func() {
+		Hash, Journal = previous, previousJournal
+		journalRecords = map[string]journalRecord{}
+	}()
+
+	journalAndLoad(t, file.Name(), []string{HashNames.MD5})
+	if _, ok := journalLookup(file.Name()); !ok {
+		t.Error("Expected journaled file to be resumed with the same hashes")
+	}
+
+	Hash = []string{HashNames.MD5, HashNames.SHA1}
+	if _, ok := journalLookup(file.Name()); ok {
+		t.Error("Expected journaled file without sha1 to be processed again")
+	}
+}
+
+func TestJournalResumeModifiedFile(t *testing.T) {
+	journal, _ := ioutil.TempFile("", "hashit")
+	file, _ := ioutil.TempFile("", "hashit")
+	defer os.Remove(journal.Name())
+	defer os.Remove(file.Name())
+	_, _ = file.WriteString("hello")
+	_ = file.Close()
+
+	previous, previousJournal := Hash, Journal
+	Journal = journal.Name()
+	defer func() {
+		Hash, Journal = previous, previousJournal
+		journalRecords = map[string]journalRecord{}
+	}()
+
+	journalAndLoad(t, file.Name(), []string{HashNames.MD5})
+
+	// Same size but modified later
+	_ = ioutil.WriteFile(file.Name(), []byte("world"), 0644)
+	_ = os.Chtimes(file.Name(), time.Now().Add(time.Hour), time.Now().Add(time.Hour))
+	if _, ok := journalLookup(file.Name()); ok {
+		t.Error("Expected modified file to be processed again")
+	}
+
+	journalAndLoad(t, file.Name(), []string{HashNames.MD5})
+	_ = ioutil.WriteFile(file.Name(), []byte("hello world"), 0644)
+	if _, ok := journalLookup(file.Name()); ok {
+		t.Error("Expected file which changed size to be processed again")
+	}
+}
@@ -0,0 +1,101 @@
+package processor
+
+import (
+	"bytes"
+	"errors"
+	"github.com/edsrzf/mmap-go"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Largest file which will be memory mapped, on 32 bit platforms the address
+// space is too small to map anything large so those fall back to streaming
+var mmapMaxSize int64 = 1 << 40
+
+func init() {
+	if strconv.IntSize == 32 {
+		mmapMaxSize = 1 << 30
+	}
+}
+
+// Only regular files with content can be mapped, special files and empty
+// files either fail to map or have no meaningful size
+func canMemoryMap(fi os.FileInfo) bool {
+	return fi.Mode().IsRegular() && fi.Size() > 0 && fi.Size() <= mmapMaxSize
+}
+
+// Turns a fault reading mapped memory into a panic and recovers from it so
+// that a file truncated while mapped is reported rather than crashing
+func recoverFault(faulted *int32) {
+	if e := recover(); e != nil {
+		if _, ok := e.(runtime.Error); !ok {
+			panic(e)
+		}
+		atomic.StoreInt32(faulted, 1)
+	}
+}
+
+// Maps the file into memory so every hash reads the same pages directly
+// without copying anything into buffers
+func processMemoryMap(filename string, file *os.File) (Result, error) {
+	m, err := mmap.Map(file, mmap.RDONLY, 0)
+	if err != nil {
+		return Result{}, err
+	}
+	defer m.Unmap()
+
+	content := []byte(m)
+
+	// Pages are read as the hashes touch them so account for it all up front
+	accountRead(int64(len(content)))
+	result := Result{}
+	var faulted int32
+	var wg sync.WaitGroup
+
+	for _, h := range streamHashers {
+		if !calculateHash(h.name) {
+			continue
+		}
+
+		h := h
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+			defer recoverFault(&faulted)
+
+			hashContent(filename, h, &content, &result)
+		}()
+	}
+
+	// Anything else which needs the content runs while the hashes are working
+	func() {
+		if !hasPerceptualHash() && !Dedup {
+			return
+		}
+
+		acquireHashThread()
+		defer releaseHashThread()
+		defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+		defer recoverFault(&faulted)
+
+		if hasPerceptualHash() {
+			processPerceptual(filename, bytes.NewReader(content), &result)
+		}
+		if Dedup {
+			result.Chunks = processChunks(&content)
+		}
+	}()
+
+	wg.Wait()
+
+	if atomic.LoadInt32(&faulted) != 0 {
+		return Result{}, errors.New("fault reading memory map, file may have been truncated")
+	}
+
+	return result, nil
+}
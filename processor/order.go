@@ -0,0 +1,110 @@
+package processor
+
+import (
+	"sort"
+	"sync"
+)
+
+// Starts the workers so that results come out in the order the files were
+// found. Every file gets its own results channel and they are read back in
+// the order they were queued so each result is passed on as soon as every
+// file before it has finished. As it is known when each file has finished
+// this is also where files are recorded in and resumed from the journal
+func orderedWorkers(fileListQueue chan string, output chan Result, threads int) {
+	jobs := make(chan fileJob, FileListQueueSize)
+	ordered := make(chan fileJob, FileListQueueSize)
+
+	go func() {
+		for path := range fileListQueue {
+			// Files which finished in a previous run are not processed again
+			if results, ok := journalLookup(path); ok {
+				job := fileJob{path: path, results: make(chan Result, len(results)), ordered: true, resumed: true}
+				for _, res := range results {
+					progressSkip(res.Bytes)
+					job.results <- res
+				}
+				close(job.results)
+				ordered <- job
+				continue
+			}
+
+			job := fileJob{path: path, results: make(chan Result, 1), ordered: true}
+			// Must be queued for reading back before any worker can pick it up
+			ordered <- job
+			jobs <- job
+		}
+		close(jobs)
+		close(ordered)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			fileJobWorker(jobs)
+			wg.Done()
+		}()
+	}
+
+	go func() {
+		for job := range ordered {
+			results := []Result{}
+			for res := range job.results {
+				output <- res
+				results = append(results, res)
+			}
+
+			if !job.resumed {
+				journalWrite(job.path, results)
+			}
+		}
+		wg.Wait()
+		close(output)
+	}()
+}
+
+// The first hash requested which is used when ordering by hash
+func orderHash(res Result) string {
+	for _, h := range streamHashers {
+		if hasHash(h.name) {
+			return h.get(res)
+		}
+	}
+
+	return ""
+}
+
+// Sorting needs every result so they are all collected before being passed on
+func sortCollector(input chan Result) chan Result {
+	output := make(chan Result, FileListQueueSize)
+
+	go func() {
+		results := []Result{}
+		for res := range input {
+			results = append(results, res)
+		}
+
+		sort.SliceStable(results, func(i, j int) bool {
+			switch Order {
+			case "size":
+				if results[i].Bytes != results[j].Bytes {
+					return results[i].Bytes < results[j].Bytes
+				}
+			case "hash":
+				hi, hj := orderHash(results[i]), orderHash(results[j])
+				if hi != hj {
+					return hi < hj
+				}
+			}
+
+			return results[i].File < results[j].File
+		})
+
+		for _, res := range results {
+			output <- res
+		}
+		close(output)
+	}()
+
+	return output
+}
@@ -0,0 +1,138 @@
+package processor
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSortCollectorSize(t *testing.T) {
+	Order = "size"
+	defer func() {
+		Order = "none"
+	}()
+
+	input := make(chan Result, 3)
+	input <- Result{File: "c", Bytes: 10}
+	input <- Result{File: "b", Bytes: 5}
+	input <- Result{File: "a", Bytes: 10}
+	close(input)
+
+	expected := []string{"b", "a", "c"}
+	i := 0
+	for res := range sortCollector(input) {
+		if res.File != expected[i] {
+			t.Errorf("Expected %s got %s", expected[i], res.File)
+		}
+		i++
+	}
+}
+
+func TestSortCollectorTieBreaks(t *testing.T) {
+	previous := Hash
+	Hash = []string{HashNames.MD5}
+	defer func() {
+		Hash = previous
+		Order = "none"
+	}()
+
+	results := []Result{
+		{File: "d", Bytes: 10, MD5: "bb"},
+		{File: "b", Bytes: 10, MD5: "aa"},
+		{File: "c", Bytes: 5, MD5: "bb"},
+		{File: "a", Bytes: 10, MD5: "bb"},
+	}
+
+	// Equal sizes and equal hashes both fall back to the path
+	for order, expected := range map[string]string{
+		"size": "c a b d",
+		"hash": "b a c d",
+		"path": "a b c d",
+	} {
+		Order = order
+		input := make(chan Result, len(results))
+		for _, res := range results {
+			input <- res
+		}
+		close(input)
+
+		var files []string
+		for res := range sortCollector(input) {
+			files = append(files, res.File)
+		}
+
+		if strings.Join(files, " ") != expected {
+			t.Errorf("Expected %s for order %s got %s", expected, order, strings.Join(files, " "))
+		}
+	}
+}
+
+func TestOrderHashFirstRequested(t *testing.T) {
+	previous := Hash
+	Hash = []string{HashNames.SHA256, HashNames.MD5}
+	defer func() {
+		Hash = previous
+	}()
+
+	// The first stream hash in the order they are listed is used whatever order they were requested in
+	if res := orderHash(Result{MD5: "md5", SHA256: "sha256"}); res != "md5" {
+		t.Errorf("Expected md5 got %s", res)
+	}
+}
+
+func TestReproducibleRunsIdentical(t *testing.T) {
+	previousHash, previousFormat, previousPaths := Hash, Format, DirFilePaths
+	previousOutput, previousOrder := FileOutput, Order
+	defer func() {
+		Hash, Format, DirFilePaths = previousHash, previousFormat, previousPaths
+		FileOutput, Order, Reproducible, Summary = previousOutput, previousOrder, false, false
+	}()
+
+	dir, err := ioutil.TempDir("", "hashit-reproducible")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tree := filepath.Join(dir, "tree")
+	for i := 0; i < 50; i++ {
+		sub := filepath.Join(tree, fmt.Sprintf("%d", i%5))
+		_ = os.MkdirAll(sub, 0755)
+		_ = ioutil.WriteFile(filepath.Join(sub, fmt.Sprintf("%02d", i)), make([]byte, (50-i)*1000), 0644)
+	}
+
+	// Run from different directories as the hashdeep header records where it was invoked from
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	var outputs []string
+	for _, from := range []string{dir, tree} {
+		_ = os.Chdir(from)
+		Hash = []string{HashNames.MD5, HashNames.SHA256}
+		Format = "hashdeep"
+		Order = "none"
+		Reproducible = true
+		Summary = true
+		progressFiles, summaryBytes = 0, 0
+		DirFilePaths = []string{tree}
+		FileOutput = filepath.Join(dir, "manifest")
+
+		Process()
+
+		content, _ := ioutil.ReadFile(FileOutput)
+		outputs = append(outputs, string(content))
+	}
+
+	if strings.Count(outputs[0], "\n") != 58 {
+		t.Errorf("Expected 3 header lines, 50 files and 5 summary lines got %s", outputs[0])
+	}
+	if strings.Contains(outputs[0], "elapsed") || strings.Contains(outputs[0], "throughput") {
+		t.Errorf("Expected no timings in the summary got %s", outputs[0])
+	}
+	if outputs[0] != outputs[1] {
+		t.Errorf("Expected identical output got %s and %s", outputs[0], outputs[1])
+	}
+}
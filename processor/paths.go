@@ -0,0 +1,87 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Checks the path options make sense together before any processing starts
+func validatePaths() error {
+	modes := 0
+	for _, set := range []bool{Relative, Absolute, Bare} {
+		if set {
+			modes++
+		}
+	}
+
+	if modes > 1 {
+		return fmt.Errorf("only one of relative, absolute or bare can be used")
+	}
+
+	return nil
+}
+
+// Check if any of the path options change how paths are shown
+func hasPathOptions() bool {
+	return Relative || Absolute || Bare || StripPrefix != "" || Prefix != "" || ForwardSlashes
+}
+
+// Works out how a path is shown using the path options, with relative paths being
+// relative to the argument the file was found under the same way as the tree hash
+func presentPath(file string) string {
+	if file == "" {
+		return file
+	}
+
+	switch {
+	case Relative:
+		file = filepath.FromSlash(treeRelativePath(file))
+	case Absolute:
+		if abs, err := filepath.Abs(file); err == nil {
+			file = abs
+		}
+	case Bare:
+		file = filepath.Base(file)
+	}
+
+	// Only whole path elements are stripped so a prefix of a/b leaves a/bc alone
+	if StripPrefix != "" && strings.HasPrefix(file, StripPrefix) {
+		rest := strings.TrimPrefix(file, StripPrefix)
+		if rest == "" || strings.ContainsAny(rest[:1], `/\`) || strings.ContainsAny(StripPrefix[len(StripPrefix)-1:], `/\`) {
+			file = strings.TrimLeft(rest, `/\`)
+		}
+	}
+
+	if ForwardSlashes {
+		file = filepath.ToSlash(file)
+	}
+
+	// The prefix is a directory so a separator is added unless it already ends in one
+	if Prefix != "" && file != "" && !strings.ContainsAny(Prefix[len(Prefix)-1:], `/\`) {
+		separator := string(filepath.Separator)
+		if ForwardSlashes {
+			separator = "/"
+		}
+		return Prefix + separator + file
+	}
+
+	return Prefix + file
+}
+
+// Sits between the workers and the formatter changing the paths of every result
+// so that sorting and auditing see the same paths as the output
+func pathCollector(input chan Result) chan Result {
+	output := make(chan Result, FileListQueueSize)
+
+	go func() {
+		for res := range input {
+			res.File = presentPath(res.File)
+			res.HardlinkOf = presentPath(res.HardlinkOf)
+			output <- res
+		}
+		close(output)
+	}()
+
+	return output
+}
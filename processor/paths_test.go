@@ -0,0 +1,42 @@
+package processor
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPresentPath(t *testing.T) {
+	defer func() {
+		Relative, Bare, StripPrefix, Prefix, ForwardSlashes = false, false, "", "", false
+		DirFilePaths = []string{}
+	}()
+
+	cases := []struct {
+		name     string
+		set      func()
+		file     string
+		expected string
+	}{
+		{"none", func() {}, "pp/abc/file", "pp/abc/file"},
+		{"strip at separator", func() { StripPrefix = "pp/a" }, "pp/a/file", "file"},
+		{"strip inside element", func() { StripPrefix = "pp/a" }, "pp/abc/file", "pp/abc/file"},
+		{"strip with trailing separator", func() { StripPrefix = "pp/a/" }, "pp/a/file", "file"},
+		{"strip whole path", func() { StripPrefix = "pp/a" }, "pp/a", ""},
+		{"prefix", func() { Prefix = "/mnt/" }, "a/file", "/mnt/a/file"},
+		{"strip and prefix", func() { StripPrefix, Prefix = "pp", "/mnt/" }, "pp/a/file", "/mnt/a/file"},
+		{"prefix without separator", func() { StripPrefix, Prefix, ForwardSlashes = "copy", "e", true }, "copy/c.txt", "e/c.txt"},
+		{"bare", func() { Bare = true }, filepath.Join("pp", "a", "file"), "file"},
+		{"relative", func() { Relative, DirFilePaths = true, []string{"pp"} }, filepath.Join("pp", "a", "file"), filepath.Join("a", "file")},
+		{"forward slashes", func() { ForwardSlashes = true }, filepath.Join("pp", "a", "file"), "pp/a/file"},
+	}
+
+	for _, c := range cases {
+		Relative, Bare, StripPrefix, Prefix, ForwardSlashes = false, false, "", "", false
+		DirFilePaths = []string{}
+		c.set()
+
+		if res := presentPath(c.file); res != c.expected {
+			t.Errorf("Expected %q for %s got %q", c.expected, c.name, res)
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif"  // register gif decoder
+	_ "image/jpeg" // register jpeg decoder
+	_ "image/png"  // register png decoder
+	"io"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Images larger than this many pixels are not decoded to avoid exhausting memory
+var perceptualMaxPixels = 100000000
+
+// Check if any of the perceptual image hashes need to be calculated
+func hasPerceptualHash() bool {
+	return calculateHash(HashNames.AHash) || calculateHash(HashNames.DHash) || calculateHash(HashNames.PHash)
+}
+
+// Checks the dimensions from the image header against the limit before
+// anything is decoded. Each is checked on its own first so a crafted header
+// cannot overflow the product and slip under the limit
+func perceptualTooLarge(width int, height int) bool {
+	if width < 0 || height < 0 || width > perceptualMaxPixels || height > perceptualMaxPixels {
+		return true
+	}
+
+	return int64(width)*int64(height) > int64(perceptualMaxPixels)
+}
+
+// Decodes the content as an image if possible and calculates the perceptual hashes for it
+// The reader is either the content already read into memory or the open file
+// which is rewound first. Content which is not a supported image format is silently ignored
+func processPerceptual(filename string, reader io.ReadSeeker, result *Result) {
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+
+	config, format, err := image.DecodeConfig(reader)
+	if err != nil {
+		return
+	}
+
+	if perceptualTooLarge(config.Width, config.Height) {
+		if Verbose {
+			printVerbose(fmt.Sprintf("image too large for perceptual hash: %s %dx%d", filename, config.Width, config.Height))
+		}
+		return
+	}
+
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+
+	startTime := makeTimestampNano()
+	img, _, err := image.Decode(reader)
+	if err != nil {
+		if Verbose {
+			printVerbose(fmt.Sprintf("unable to decode %s image %s: %s", format, filename, err.Error()))
+		}
+		return
+	}
+
+	if calculateHash(HashNames.AHash) {
+		result.AHash = perceptualHex(averageHash(img))
+	}
+	if calculateHash(HashNames.DHash) {
+		result.DHash = perceptualHex(differenceHash(img))
+	}
+	if calculateHash(HashNames.PHash) {
+		result.PHash = perceptualHex(phash(img))
+	}
+
+	if Trace {
+		printTrace(fmt.Sprintf("nanoseconds processing perceptual: %s: %d", filename, makeTimestampNano()-startTime))
+	}
+}
+
+func perceptualHex(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// Shrinks the image to the supplied size in grayscale by averaging every
+// source pixel that falls into each destination pixel
+func grayscale(img image.Image, width int, height int) []float64 {
+	bounds := img.Bounds()
+	sw, sh := bounds.Dx(), bounds.Dy()
+
+	sums := make([]float64, width*height)
+	counts := make([]float64, width*height)
+
+	for y := 0; y < sh; y++ {
+		ty := y * height / sh
+		for x := 0; x < sw; x++ {
+			tx := x * width / sw
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			// ITU-R 601 luma using the 16 bit channel values
+			sums[ty*width+tx] += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			counts[ty*width+tx]++
+		}
+	}
+
+	for i := range sums {
+		if counts[i] != 0 {
+			sums[i] = sums[i] / counts[i]
+		}
+	}
+
+	return sums
+}
+
+// aHash sets a bit for every pixel of an 8x8 thumbnail brighter than the mean
+func averageHash(img image.Image) uint64 {
+	pixels := grayscale(img, 8, 8)
+
+	mean := 0.0
+	for _, p := range pixels {
+		mean += p
+	}
+	mean = mean / float64(len(pixels))
+
+	var hash uint64
+	for i, p := range pixels {
+		if p > mean {
+			hash |= 1 << uint(63-i)
+		}
+	}
+
+	return hash
+}
+
+// dHash sets a bit for every pixel of a 9x8 thumbnail brighter than its right neighbour
+func differenceHash(img image.Image) uint64 {
+	pixels := grayscale(img, 9, 8)
+
+	var hash uint64
+	i := 0
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if pixels[y*9+x] > pixels[y*9+x+1] {
+				hash |= 1 << uint(63-i)
+			}
+			i++
+		}
+	}
+
+	return hash
+}
+
+// pHash takes the DCT of a 32x32 thumbnail and sets a bit for every one of
+// the 8x8 lowest frequencies which is above the median of those frequencies
+func phash(img image.Image) uint64 {
+	const size = 32
+	pixels := grayscale(img, size, size)
+
+	// Precompute the cosine table for the separable 2D DCT-II
+	cosines := make([]float64, size*size)
+	for u := 0; u < size; u++ {
+		for x := 0; x < size; x++ {
+			cosines[u*size+x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * size))
+		}
+	}
+
+	// Rows first then only the 8 lowest frequency columns are needed
+	rows := make([]float64, size*8)
+	for y := 0; y < size; y++ {
+		for u := 0; u < 8; u++ {
+			sum := 0.0
+			for x := 0; x < size; x++ {
+				sum += pixels[y*size+x] * cosines[u*size+x]
+			}
+			rows[y*8+u] = sum
+		}
+	}
+
+	freq := make([]float64, 64)
+	for v := 0; v < 8; v++ {
+		for u := 0; u < 8; u++ {
+			sum := 0.0
+			for y := 0; y < size; y++ {
+				sum += rows[y*8+u] * cosines[v*size+y]
+			}
+			freq[v*8+u] = sum
+		}
+	}
+
+	sorted := make([]float64, len(freq))
+	copy(sorted, freq)
+	sort.Float64s(sorted)
+	median := (sorted[31] + sorted[32]) / 2
+
+	var hash uint64
+	for i, f := range freq {
+		if f > median {
+			hash |= 1 << uint(63-i)
+		}
+	}
+
+	return hash
+}
+
+// Picks the best perceptual hash available on the result for comparison
+func similarityHash(res Result) string {
+	switch {
+	case res.PHash != "":
+		return res.PHash
+	case res.DHash != "":
+		return res.DHash
+	}
+	return res.AHash
+}
+
+var similarResults = []Result{}
+var similarMutex = sync.Mutex{}
+
+// Sits between the workers and the formatter recording every image result so
+// that similar images can be clustered once everything has been processed
+func similarCollector(input chan Result) chan Result {
+	output := make(chan Result, FileListQueueSize)
+
+	go func() {
+		for res := range input {
+			if similarityHash(res) != "" {
+				similarMutex.Lock()
+				similarResults = append(similarResults, res)
+				similarMutex.Unlock()
+			}
+
+			// The pHash is only shown if it was asked for and not just calculated for clustering
+			if !hasHash(HashNames.PHash) {
+				res.PHash = ""
+			}
+
+			output <- res
+		}
+		close(output)
+	}()
+
+	return output
+}
+
+// A BK-tree keyed on the hamming distance between hashes which allows every
+// hash within a distance to be found without comparing against all of them
+type bkNode struct {
+	hash     uint64
+	indices  []int
+	children map[int]*bkNode
+}
+
+// Hamming distance is the count of bits which differ
+func hammingDistance(a uint64, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+func (n *bkNode) insert(hash uint64, index int) {
+	for {
+		d := hammingDistance(n.hash, hash)
+		if d == 0 {
+			n.indices = append(n.indices, index)
+			return
+		}
+
+		child, ok := n.children[d]
+		if !ok {
+			if n.children == nil {
+				n.children = map[int]*bkNode{}
+			}
+			n.children[d] = &bkNode{hash: hash, indices: []int{index}}
+			return
+		}
+		n = child
+	}
+}
+
+// Calls found with the index of every hash within the distance of the supplied one
+func (n *bkNode) search(hash uint64, distance int, found func(int)) {
+	d := hammingDistance(n.hash, hash)
+	if d <= distance {
+		for _, i := range n.indices {
+			found(i)
+		}
+	}
+
+	// By the triangle inequality only children whose distance from this
+	// node is within the distance of d can hold a match
+	for k, child := range n.children {
+		if k >= d-distance && k <= d+distance {
+			child.search(hash, distance, found)
+		}
+	}
+}
+
+// Groups the images whose hashes are closer than the threshold using union find
+func similarClusters() [][]string {
+	similarMutex.Lock()
+	defer similarMutex.Unlock()
+
+	hashes := make([]uint64, len(similarResults))
+	parent := make([]int, len(similarResults))
+	for i := range parent {
+		hashes[i], _ = strconv.ParseUint(similarityHash(similarResults[i]), 16, 64)
+		parent[i] = i
+	}
+
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	// Each image is compared only against those already in the tree which
+	// could be close enough rather than against every other image
+	var tree *bkNode
+	for i, hash := range hashes {
+		if tree == nil {
+			tree = &bkNode{hash: hash, indices: []int{i}}
+			continue
+		}
+
+		tree.search(hash, Similar-1, func(j int) {
+			parent[find(j)] = find(i)
+		})
+		tree.insert(hash, i)
+	}
+
+	groups := map[int][]string{}
+	for i, res := range similarResults {
+		root := find(i)
+		groups[root] = append(groups[root], res.File)
+	}
+
+	clusters := [][]string{}
+	for _, files := range groups {
+		if len(files) > 1 {
+			sort.Strings(files)
+			clusters = append(clusters, files)
+		}
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i][0] < clusters[j][0]
+	})
+
+	return clusters
+}
+
+// Formats the similar image clusters to match the output format
+func toSimilar() string {
+	clusters := similarClusters()
+
+	if jsonFormat() {
+		jsonString, _ := json.Marshal(struct {
+			Similar   [][]string
+			Threshold int
+		}{clusters, Similar})
+		return string(jsonString) + "\n"
+	}
+
+	prefix := footerPrefix()
+
+	var str strings.Builder
+	str.WriteString(fmt.Sprintf("%ssimilar images (distance < %d) %d clusters\n", prefix, Similar, len(clusters)))
+	for i, files := range clusters {
+		str.WriteString(fmt.Sprintf("%s  cluster %d\n", prefix, i+1))
+		for _, f := range files {
+			str.WriteString(fmt.Sprintf("%s    %s\n", prefix, f))
+		}
+	}
+
+	return str.String()
+}
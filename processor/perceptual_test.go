@@ -0,0 +1,249 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"math/bits"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestAverageHashHalfWhite(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 32; x < 64; x++ {
+			img.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+
+	res := perceptualHex(averageHash(img))
+
+	if res != "0f0f0f0f0f0f0f0f" {
+		t.Errorf("Expected 0f0f0f0f0f0f0f0f got %s", res)
+	}
+}
+
+func TestDifferenceHashUniform(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 100, 50))
+
+	res := perceptualHex(differenceHash(img))
+
+	if res != "0000000000000000" {
+		t.Errorf("Expected 0000000000000000 got %s", res)
+	}
+}
+
+func TestProcessPerceptualOpenFile(t *testing.T) {
+	previous := Hash
+	Hash = []string{HashNames.AHash}
+	defer func() {
+		Hash = previous
+	}()
+
+	img := image.NewGray(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 32; x < 64; x++ {
+			img.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+
+	file, err := ioutil.TempFile("", "hashit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	_ = png.Encode(file, img)
+
+	// The stream has already read the file to the end so it must be rewound
+	_, _ = file.Seek(0, io.SeekEnd)
+	res := Result{}
+	processPerceptual(file.Name(), file, &res)
+
+	if res.AHash != "0f0f0f0f0f0f0f0f" {
+		t.Errorf("Expected 0f0f0f0f0f0f0f0f got %s", res.AHash)
+	}
+}
+
+// A diagonal gradient with a bright disc left of centre drawn at any size
+func perceptualTestImage(width int, height int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := (x*96/width + y*64/height) * 255 / 160
+			dx, dy := x*96/width-30, y*64/height-32
+			if dx*dx+dy*dy < 400 {
+				v = 255 - v/2
+			}
+			img.SetGray(x, y, color.Gray{Y: uint8(v)})
+		}
+	}
+	return img
+}
+
+func TestPerceptualKnownVectors(t *testing.T) {
+	img := perceptualTestImage(96, 64)
+
+	if res := perceptualHex(averageHash(img)); res != "010173777f7f3f1f" {
+		t.Errorf("Expected 010173777f7f3f1f got %s", res)
+	}
+
+	if res := perceptualHex(differenceHash(img)); res != "0030387878301000" {
+		t.Errorf("Expected 0030387878301000 got %s", res)
+	}
+
+	if res := perceptualHex(phash(img)); res != "80473f3978ecc2c7" {
+		t.Errorf("Expected 80473f3978ecc2c7 got %s", res)
+	}
+}
+
+func TestPerceptualResizedWithinDistance(t *testing.T) {
+	large := perceptualTestImage(96, 64)
+	small := perceptualTestImage(48, 32)
+
+	for _, h := range []struct {
+		name string
+		hash func(image.Image) uint64
+	}{
+		{"ahash", averageHash},
+		{"dhash", differenceHash},
+		{"phash", phash},
+	} {
+		if d := hammingDistance(h.hash(large), h.hash(small)); d >= 8 {
+			t.Errorf("Expected %s distance under 8 for a resized image got %d", h.name, d)
+		}
+	}
+}
+
+func TestSimilarClustersMatchesPairwise(t *testing.T) {
+	previousResults, previousSimilar := similarResults, Similar
+	defer func() {
+		similarResults, Similar = previousResults, previousSimilar
+	}()
+
+	// Groups of hashes a few bits apart from a handful of random bases with duplicates
+	random := rand.New(rand.NewSource(1))
+	var hashes []uint64
+	similarResults = []Result{}
+	for i := 0; i < 300; i++ {
+		hash := uint64(random.Intn(8)) * 0x9e3779b97f4a7c15
+		for j := random.Intn(6); j > 0; j-- {
+			hash ^= 1 << uint(random.Intn(64))
+		}
+		hashes = append(hashes, hash)
+		similarResults = append(similarResults, Result{File: fmt.Sprintf("%03d.png", i), PHash: perceptualHex(hash)})
+	}
+
+	for _, similar := range []int{1, 4, 10} {
+		Similar = similar
+
+		parent := make([]int, len(hashes))
+		for i := range parent {
+			parent[i] = i
+		}
+		var find func(int) int
+		find = func(i int) int {
+			if parent[i] != i {
+				parent[i] = find(parent[i])
+			}
+			return parent[i]
+		}
+		for i := range hashes {
+			for j := i + 1; j < len(hashes); j++ {
+				if hammingDistance(hashes[i], hashes[j]) < similar {
+					parent[find(j)] = find(i)
+				}
+			}
+		}
+
+		groups := map[int][]string{}
+		for i, res := range similarResults {
+			groups[find(i)] = append(groups[find(i)], res.File)
+		}
+		var expected []string
+		for _, files := range groups {
+			if len(files) > 1 {
+				sort.Strings(files)
+				expected = append(expected, strings.Join(files, " "))
+			}
+		}
+		sort.Strings(expected)
+
+		var actual []string
+		for _, files := range similarClusters() {
+			actual = append(actual, strings.Join(files, " "))
+		}
+
+		if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("Expected %d clusters matching pairwise at distance %d got %d", len(expected), similar, len(actual))
+		}
+	}
+}
+
+func TestPerceptualTooLarge(t *testing.T) {
+	cases := []struct {
+		width, height int
+		expected      bool
+	}{
+		{10000, 10000, false},
+		{10001, 10000, true},
+		{1, perceptualMaxPixels + 1, true},
+		{-1, 5, true},
+		// The product of these wraps around to 0 as an int
+		{1 << (bits.UintSize / 2), 1 << (bits.UintSize / 2), true},
+	}
+
+	for _, c := range cases {
+		if res := perceptualTooLarge(c.width, c.height); res != c.expected {
+			t.Errorf("Expected %t for %dx%d got %t", c.expected, c.width, c.height, res)
+		}
+	}
+}
+
+func TestSimilarKeepsRequestedHashes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hashit-similar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func() {
+		Similar = 0
+	}()
+
+	for _, size := range []int{96, 48} {
+		file, _ := os.Create(filepath.Join(dir, fmt.Sprintf("%d.png", size)))
+		_ = png.Encode(file, perceptualTestImage(size, size*2/3))
+		_ = file.Close()
+	}
+
+	Hash = []string{HashNames.MD5}
+	Format = "json"
+	Similar = 8
+	output := processOutput(t, dir)
+
+	// The pHash is calculated to cluster the images without being shown
+	var document struct {
+		Results []Result
+		Similar struct{ Similar [][]string }
+	}
+	if err := json.Unmarshal([]byte(output), &document); err != nil {
+		t.Fatalf("Expected a single json document got %s", output)
+	}
+	for _, res := range document.Results {
+		if res.PHash != "" || res.MD5 == "" {
+			t.Errorf("Expected only the md5 got %v", res)
+		}
+	}
+	if len(document.Similar.Similar) != 1 {
+		t.Errorf("Expected 1 cluster got %s", output)
+	}
+}
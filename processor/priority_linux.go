@@ -0,0 +1,43 @@
+//go:build linux
+// +build linux
+
+package processor
+
+import (
+	"golang.org/x/sys/unix"
+	"io/ioutil"
+	"strconv"
+)
+
+// Best effort scheduling class at its lowest level so reads only happen
+// when other processes are not waiting on the disk
+const ioprioClassBestEffort = 2
+const ioprioClassShift = 13
+const ioprioLowest = 7
+
+// Linux applies both nice and io priority per thread so every thread the
+// runtime has started is changed, threads started later inherit them
+func lowerPriority() error {
+	tasks, err := ioutil.ReadDir("/proc/self/task")
+	if err != nil {
+		return err
+	}
+
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+
+		if err := unix.Setpriority(unix.PRIO_PROCESS, tid, 19); err != nil {
+			return err
+		}
+
+		_, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, 1, uintptr(tid), ioprioClassBestEffort<<ioprioClassShift|ioprioLowest)
+		if errno != 0 {
+			return errno
+		}
+	}
+
+	return nil
+}
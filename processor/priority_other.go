@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package processor
+
+import (
+	"errors"
+)
+
+// Only implemented for Linux where both nice and io priority can be set
+func lowerPriority() error {
+	return errors.New("lowering priority is not supported on this platform")
+}
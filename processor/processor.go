@@ -4,10 +4,10 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"runtime"
 	"strings"
 	"sync"
 )
@@ -24,8 +24,8 @@ var Debug = false
 // Trace enables trace logging output which is extremely verbose
 var Trace = false
 
-// Recursive to walk directories
-var Recursive = false
+// Recursive to walk directories, when not set directories are skipped
+var Recursive = true
 
 // Do not print out results as they are processed
 var NoStream = false
@@ -55,12 +55,180 @@ var isDir = false
 // FileListQueueSize is the queue of files found and ready to be processed
 var FileListQueueSize = 1000
 
+// IOThreads is the number of files read at once, 0 picks a default based on the storage being read
+var IOThreads = 0
+
+// HashThreads is the number of hashes calculated at once across every file, 0 uses every core
+var HashThreads = 0
+
+// MaxRate limits how many bytes per second are read across every reader e.g. 50M, empty is unlimited
+var MaxRate = ""
+
+// LowPriority lowers the CPU and I/O priority so hashing does not slow down other processes
+var LowPriority = false
+
+// Order of the results, none prints them as soon as they are ready, walk in the order the files were found
+// as soon as each is ready, path, size or hash once every file has been processed
+var Order = "none"
+
+// Reproducible produces the same output for the same files every time by sorting by path and leaving out
+// anything which depends on how or where hashit was run
+var Reproducible = false
+
+// Cache is a file used to store hashes between runs so files which have not changed are not read again
+var Cache = ""
+
+// CacheVerify reads every file even if cached reporting any whose content changed without its metadata changing
+var CacheVerify = false
+
+// CachePrune removes cache entries for files which no longer exist or have changed
+var CachePrune = false
+
+// CacheStats prints the cache hit ratio to stderr
+var CacheStats = false
+
+// Hardlinks hashes each hard linked inode once copying the result to every other path linked to it
+var Hardlinks = false
+
+// Progress draws the progress on stderr while files are processed
+var Progress = false
+
+// Prescan totals the files and bytes to be processed so progress can show a percentage and ETA
+var Prescan = false
+
+// FilesFrom is a file containing the list of files to process, - reads the list from stdin
+var FilesFrom = ""
+
+// NullDelimited is set when the list of files is separated by NUL rather than newlines
+var NullDelimited = false
+
+// Include only processes files found while walking whose relative path matches one of these globs
+var Include = []string{}
+
+// Exclude skips files and directories found while walking whose relative path matches one of these globs
+var Exclude = []string{}
+
+// IgnoreFiles honours .gitignore, .ignore and .hashitignore files and skips .git directories while walking
+var IgnoreFiles = false
+
+// Summary appends the totals for the run along with every path which failed or was skipped
+var Summary = false
+
+// Offset is where in each file to start hashing from e.g. 1M
+var Offset = ""
+
+// Length is how much of each file to hash from the offset e.g. 512M, empty is to the end
+var Length = ""
+
+// SectorSize reads files only at offsets and in sizes which are a multiple of it, 0 reads normally
+var SectorSize = 0
+
+// Relative shows paths relative to the directory argument they were found under
+var Relative = false
+
+// Absolute shows absolute paths
+var Absolute = false
+
+// Bare shows only the name of each file without the directory
+var Bare = false
+
+// StripPrefix is removed from the start of every path shown
+var StripPrefix = ""
+
+// Prefix is added to the start of every path shown
+var Prefix = ""
+
+// ForwardSlashes shows paths with / as the separator on every OS so manifests can be shared
+var ForwardSlashes = false
+
+// MinSize skips files found while walking which are smaller than this size e.g. 1G, empty is no limit
+var MinSize = ""
+
+// MaxSize skips files found while walking which are larger than this size e.g. 100M, empty is no limit
+var MaxSize = ""
+
+// NewerThan skips files found while walking modified before this duration ago or timestamp e.g. 24h or 2018-06-01
+var NewerThan = ""
+
+// OlderThan skips files found while walking modified after this duration ago or timestamp e.g. 7d or 2018-06-01
+var OlderThan = ""
+
+// MaxDepth is how many directories deep to walk, 1 is only the files in the directory, -1 is unlimited
+var MaxDepth = -1
+
+// Hidden walks files and directories whose names start with a dot
+var Hidden = true
+
+// OneFileSystem skips directories on a different filesystem to the one being walked
+var OneFileSystem = false
+
+// Symlinks controls what happens to symlinks, files hashes what links to files point to without walking
+// linked directories, follow also walks linked directories, skip ignores them and record hashes the path
+// the link points to. The default of files is how links were always handled before there was a choice
+var Symlinks = "files"
+
+// Journal is a file every finished file and its results are written to as the run progresses
+var Journal = ""
+
+// Resume skips files which finished according to the journal reusing their results from it
+var Resume = false
+
 // Number of bytes in a size to enable memory maps or streaming
 var StreamSize int64 = 1000000
 
+// Strategy controls how files are read, auto reads small files into memory and memory maps larger ones
+// falling back to streaming if the map fails, otherwise one of read, stream or mmap
+var Strategy = "auto"
+
 // If set will enable the internal file audit logic to kick in
 var FileAudit = false
 
+// TreeHash appends a single digest covering every processed file to the output
+var TreeHash = false
+
+// TreeHashOnly prints the tree digest without the individual file results
+var TreeHashOnly = false
+
+// TreeHashModes includes the mode of each file in the tree digest
+var TreeHashModes = false
+
+// TreeHashEmptyDirs includes empty directories in the tree digest
+var TreeHashEmptyDirs = false
+
+// GoDirHash prints the Go module h1: hash for each directory or module zip
+var GoDirHash = false
+
+// GoDirHashPrefix is the module@version prefix used for file names when hashing a directory
+var GoDirHashPrefix = ""
+
+// GoSumFile is a go.sum file to verify the vendor directory against
+var GoSumFile = ""
+
+// Archives hashes the files inside zip and tar archives rather than the archive itself
+var Archives = false
+
+// Decompress hashes the decompressed content of gzip, bzip2 and zlib files
+// set to also to hash it alongside the raw content or only to replace it
+var Decompress = ""
+
+// Dedup splits files into content defined chunks and reports how much of the data is unique
+var Dedup = false
+
+// ChunkMin is the smallest chunk in bytes that will be cut when using dedup
+var ChunkMin = 2048
+
+// ChunkAvg is the average chunk size in bytes that will be aimed for when using dedup
+var ChunkAvg = 8192
+
+// ChunkMax is the largest chunk in bytes that will be cut when using dedup
+var ChunkMax = 65536
+
+// ChunkList includes the offset, length and SHA256 of every chunk in the results
+var ChunkList = false
+
+// Similar clusters images whose perceptual hashes differ by fewer than this many bits
+var Similar = 0
+
 // String mapping for hash names
 var HashNames = Result{
 	MD4:        "md4",
@@ -74,6 +242,9 @@ var HashNames = Result{
 	Sha3256:    "sha3256",
 	Sha3384:    "sha3384",
 	Sha3512:    "sha3512",
+	AHash:      "ahash",
+	DHash:      "dhash",
+	PHash:      "phash",
 }
 
 // Raw hashDatabase loaded
@@ -122,37 +293,202 @@ func Process() {
 		ProcessConstants()
 	}
 
-	if AuditFile != "" {
-		loadAuditFile()
+	if FileListQueueSize <= 0 {
+		printError(fmt.Sprintf("file-list-queue-size must be greater than 0 got %d", FileListQueueSize))
+		exit(1)
+	}
+
+	setupHashThreads()
+
+	if MaxRate != "" {
+		rate, err := parseSize(MaxRate)
+		if err != nil {
+			printError(fmt.Sprintf("max-rate %s", err.Error()))
+			exit(1)
+		}
+		if rate <= 0 {
+			printError(fmt.Sprintf("max-rate must be at least 1 byte per second got %s", MaxRate))
+			exit(1)
+		}
+		maxRateBytes = rate
+	}
+
+	if LowPriority {
+		if err := lowerPriority(); err != nil {
+			printError(fmt.Sprintf("unable to lower priority: %s", err.Error()))
+		} else if Verbose {
+			printVerbose("lowered cpu and io priority")
+		}
 	}
 
-	// Check if we are accepting data from stdin
-	stat, _ := os.Stdin.Stat()
-	if (stat.Mode() & os.ModeCharDevice) == 0 {
+	// Go module hashes have their own output so run them and bail out
+	if GoDirHash || GoSumFile != "" {
+		var valid bool
+		if GoSumFile != "" {
+			valid = processGoSumVerify()
+		} else {
+			valid = processGoDirHash()
+		}
+
+		if !valid {
+			exit(1)
+		}
+		return
+	}
+
+	// Stdin is processed if asked for using - or if something is piped in and nothing else
+	// was supplied to run against, explicit paths always win over whatever is on stdin
+	for _, f := range DirFilePaths {
+		if f == "-" && (len(DirFilePaths) != 1 || FilesFrom != "") {
+			printError("- reads from stdin and must be the only file or directory without --files-from")
+			exit(1)
+		}
+	}
+	if len(DirFilePaths) == 1 && DirFilePaths[0] == "-" {
 		StandardInput = true
+	} else if len(DirFilePaths) == 0 && FilesFrom == "" {
+		stat, _ := os.Stdin.Stat()
+		if (stat.Mode() & os.ModeCharDevice) == 0 {
+			StandardInput = true
+		}
 	}
 
 	// If nothing was supplied as an argument to run against assume run against everything in the
 	// current directory recursively
-	if len(DirFilePaths) == 0 {
+	if len(DirFilePaths) == 0 && FilesFrom == "" {
 		DirFilePaths = append(DirFilePaths, ".")
 	}
 
-	// If a single argument is supplied enable recursive as if its a file no problem
-	// but if its a directory the user probably wants to hash everything in that directory
-	if len(DirFilePaths) == 1 {
-		Recursive = true
+	Symlinks = strings.ToLower(Symlinks)
+	if Symlinks != "files" && Symlinks != "follow" && Symlinks != "skip" && Symlinks != "record" {
+		printError(fmt.Sprintf("symlinks must be one of files, follow, skip or record got %s", Symlinks))
+		exit(1)
+	}
+
+	if err := compileFilters(); err != nil {
+		printError(err.Error())
+		exit(1)
+	}
+	if err := validatePaths(); err != nil {
+		printError(err.Error())
+		exit(1)
+	}
+	if err := parseRegion(); err != nil {
+		printError(err.Error())
+		exit(1)
+	}
+	if err := parseFilters(); err != nil {
+		printError(err.Error())
+		exit(1)
 	}
 
 	// Clean up hashes by setting all input to lowercase
 	Hash = formatHashInput()
 
+	if ChunkList {
+		Dedup = true
+	}
+	if Dedup {
+		if err := validateChunkSizes(); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+	}
+
+	Decompress = strings.ToLower(Decompress)
+	if Decompress != "" && Decompress != "also" && Decompress != "only" {
+		printError(fmt.Sprintf("decompress must be one of also or only not %s", Decompress))
+		exit(1)
+	}
+
+	// Reproducible output needs a stable order so default to sorting by path
+	if Reproducible && (Order == "" || Order == "none") {
+		Order = "path"
+	}
+
+	Order = strings.ToLower(Order)
+	if Order != "none" && Order != "walk" && Order != "path" && Order != "size" && Order != "hash" {
+		printError(fmt.Sprintf("order must be one of none, walk, path, size or hash not %s", Order))
+		exit(1)
+	}
+
+	Strategy = strings.ToLower(Strategy)
+	if Strategy != "auto" && Strategy != "read" && Strategy != "stream" && Strategy != "mmap" {
+		printError(fmt.Sprintf("strategy must be one of auto, read, stream or mmap not %s", Strategy))
+		exit(1)
+	}
+
+	if TreeHashOnly {
+		TreeHash = true
+	}
+
+	// The tree digest is built from the SHA256 of every file so ensure it is
+	// calculated without showing it unless it was asked for
+	internalHashes = []string{}
+	if TreeHash && !hasHash(HashNames.SHA256) {
+		internalHashes = append(internalHashes, HashNames.SHA256)
+	}
+
+	if Similar < 0 {
+		printError(fmt.Sprintf("similar must be 0 or greater got %d", Similar))
+		exit(1)
+	}
+
+	// Clustering similar images needs at least one perceptual hash which is
+	// calculated without showing it unless it was asked for
+	if Similar > 0 && !hasPerceptualHash() {
+		internalHashes = append(internalHashes, HashNames.PHash)
+	}
+
+	// Every hash in the audit file is needed to compare against
+	if AuditFile != "" {
+		loadAuditFile()
+	}
+
+	if Cache != "" {
+		loadCache()
+	}
+
+	if Resume && Journal == "" {
+		printError("resume needs a journal to resume from, use --journal")
+		exit(1)
+	}
+
+	if Journal != "" && !StandardInput {
+		if Resume && Dedup {
+			printError("resume cannot be used with dedup as chunk statistics are not journaled")
+			exit(1)
+		}
+		openJournal()
+	}
+
 	// Results ready to be printed
 	fileSummaryQueue := make(chan Result, FileListQueueSize)
 
+	// Progress and the status signal cover stdin as well but there is nothing to prescan
 	if StandardInput {
+		progressFile("stdin")
+		startProgress(nil)
+	}
+
+	if StandardInput && Archives {
+		go processArchiveStandardInput(fileSummaryQueue)
+	} else if StandardInput && Decompress != "" {
+		go processDecompressStandardInput(fileSummaryQueue)
+	} else if StandardInput {
 		go processStandardInput(fileSummaryQueue)
 	} else {
+		// The list of files is opened here so a missing list stops the run before anything starts
+		var filesFrom io.ReadCloser
+		if FilesFrom != "" {
+			var err error
+			filesFrom, err = openFilesFrom()
+			if err != nil {
+				printError(fmt.Sprintf("unable to open files from: %s %s", FilesFrom, err.Error()))
+				exit(1)
+			}
+		}
+
 		// Files ready to be read from disk
 		fileListQueue := make(chan string, FileListQueueSize)
 
@@ -166,12 +502,14 @@ func Process() {
 				// If there is an error which is usually does not exist then exit non zero
 				if err != nil {
 					printError(fmt.Sprintf("file or directory issue: %s %s", fp, err.Error()))
-					os.Exit(1)
+					exit(1)
 				} else {
 					if fi.IsDir() {
 						if Recursive {
 							isDir = true
 							walkDirectory(fp, fileListQueue)
+						} else {
+							reportSkipped(&skippedDirectories, fp, "directory with --no-recursive set")
 						}
 					} else {
 						fileListQueue <- fp
@@ -179,35 +517,113 @@ func Process() {
 				}
 
 			}
+
+			if filesFrom != nil {
+				readFilesFrom(filesFrom, fileListQueue)
+				_ = filesFrom.Close()
+			}
 			close(fileListQueue)
 		}()
 
-		var wg sync.WaitGroup
-		for i := 0; i < runtime.NumCPU(); i++ {
-			wg.Add(1)
+		startProgress(DirFilePaths)
+
+		threads := ioThreads(DirFilePaths)
+		if Order == "walk" || Journal != "" {
+			orderedWorkers(fileListQueue, fileSummaryQueue, threads)
+		} else {
+			var wg sync.WaitGroup
+			for i := 0; i < threads; i++ {
+				wg.Add(1)
+				go func() {
+					fileProcessorWorker(fileListQueue, fileSummaryQueue)
+					wg.Done()
+				}()
+			}
+
 			go func() {
-				fileProcessorWorker(fileListQueue, fileSummaryQueue)
-				wg.Done()
+				wg.Wait()
+				close(fileSummaryQueue)
 			}()
 		}
+	}
 
-		go func() {
-			wg.Wait()
-			close(fileSummaryQueue)
-		}()
+	var result string
+	valid := true
+
+	// Results may pass through additional collectors before being formatted
+	formatQueue := progressCollector(fileSummaryQueue)
+	if TreeHash {
+		formatQueue = treeHashCollector(formatQueue)
+	}
+	if Similar > 0 {
+		formatQueue = similarCollector(formatQueue)
+	}
+	if hasPathOptions() && !StandardInput {
+		formatQueue = pathCollector(formatQueue)
+	}
+	if Order == "path" || Order == "size" || Order == "hash" {
+		formatQueue = sortCollector(formatQueue)
 	}
 
-	result, valid := fileSummarize(fileSummaryQueue)
+	if AuditFile != "" {
+		for res := range formatQueue {
+			auditResult(res)
+		}
+		result, valid = toAudit()
+	} else if TreeHashOnly {
+		for range formatQueue {
+		}
+	} else {
+		result, valid = fileSummarize(formatQueue)
+	}
+	stopProgress()
+
+	// Anything calculated over the whole run is printed after the results
+	footers := []runFooter{}
+	if TreeHash {
+		footers = append(footers, runFooter{"Tree", toTreeHash()})
+	}
+	if Similar > 0 {
+		footers = append(footers, runFooter{"Similar", toSimilar()})
+	}
+	if Dedup {
+		footers = append(footers, runFooter{"Dedup", toDedup()})
+	}
+	if Summary {
+		footers = append(footers, runFooter{"Summary", toSummary()})
+	}
+	result = appendFooters(result, footers)
+
+	closeJournal()
+	printRunIssues()
 
+	if Cache != "" {
+		saveCache()
+		if CacheStats {
+			printCacheStats()
+		}
+	}
+
+	writeOutput(result)
+	if FileOutput == "" && !valid {
+		exit(1)
+	}
+
+	// Anything which could not be processed means the results are incomplete
+	if errorCount() != 0 {
+		exit(2)
+	}
+}
+
+// Writes the result to the output file if one was set otherwise to stdout
+func writeOutput(result string) {
 	if FileOutput == "" {
 		fmt.Print(result)
-		if !valid {
-			os.Exit(1)
-		}
-	} else {
-		_ = ioutil.WriteFile(FileOutput, []byte(result), 0600)
-		fmt.Println("results written to " + FileOutput)
+		return
 	}
+
+	_ = ioutil.WriteFile(FileOutput, []byte(result), 0600)
+	fmt.Println("results written to " + FileOutput)
 }
 
 // ToLower all of the input hashes so we can match them easily
@@ -220,6 +636,25 @@ func formatHashInput() []string {
 }
 
 // Check if a hash was supplied to the input so we know if we should calculate it
+// Hashes which are calculated for something else such as the tree digest
+// without being asked for so they are left out of the output
+var internalHashes = []string{}
+
+// Checks if the hash needs to be calculated because it was asked for or is needed internally
+func calculateHash(hash string) bool {
+	if hasHash(hash) {
+		return true
+	}
+
+	for _, x := range internalHashes {
+		if x == hash {
+			return true
+		}
+	}
+
+	return false
+}
+
 func hasHash(hash string) bool {
 	for _, x := range Hash {
 		if x == "all" {
@@ -253,18 +688,3 @@ func loadDatabase() map[string]Result {
 
 	return database
 }
-
-func loadAuditFile() {
-	content, err := ioutil.ReadFile(AuditFile)
-
-	if err != nil {
-		printError(fmt.Sprintf("unable to load audit file: %s %s", AuditFile, err.Error()))
-		os.Exit(1)
-	}
-
-	if strings.HasPrefix(strings.Trim(string(content), ""), "[{") {
-		fmt.Println("JSON audit file")
-	} else {
-		fmt.Println("HASHDEEP audit file")
-	}
-}
@@ -0,0 +1,102 @@
+package processor
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Runs the whole of Process over the paths returning what was written to the output file
+func processOutput(t *testing.T, paths ...string) string {
+	previousHash, previousFormat, previousPaths, previousOutput := Hash, Format, DirFilePaths, FileOutput
+	defer func() {
+		Hash, Format, DirFilePaths, FileOutput = previousHash, previousFormat, previousPaths, previousOutput
+		treeEntries = []treeEntry{}
+		similarResults = []Result{}
+	}()
+
+	output, err := ioutil.TempFile("", "hashit-output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = output.Close()
+	defer os.Remove(output.Name())
+
+	DirFilePaths = paths
+	FileOutput = output.Name()
+	Process()
+
+	content, _ := ioutil.ReadFile(output.Name())
+	return string(content)
+}
+
+// Creates a directory holding a few small files returning its path
+func processTestDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "hashit-process")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"a", "b", "c"} {
+		_ = ioutil.WriteFile(filepath.Join(dir, name), []byte(name+"\n"), 0644)
+	}
+
+	return dir
+}
+
+func TestJSONFootersSingleDocument(t *testing.T) {
+	dir := processTestDir(t)
+	defer os.RemoveAll(dir)
+	defer func() {
+		TreeHash, Summary, Dedup = false, false, false
+	}()
+
+	Hash = []string{HashNames.MD5}
+	Format = "json"
+
+	cases := []struct {
+		treeHash, summary, dedup bool
+		keys                     []string
+	}{
+		{false, false, false, nil},
+		{true, false, false, []string{"Results", "Tree"}},
+		{true, true, false, []string{"Results", "Tree", "Summary"}},
+		{false, true, true, []string{"Results", "Dedup", "Summary"}},
+	}
+
+	for _, c := range cases {
+		TreeHash, Summary, Dedup = c.treeHash, c.summary, c.dedup
+		output := processOutput(t, dir)
+
+		// Without any footers the results are the array they have always been
+		if c.keys == nil {
+			var results []Result
+			if err := json.Unmarshal([]byte(output), &results); err != nil || len(results) != 3 {
+				t.Errorf("Expected an array of 3 results got %s %v", output, err)
+			}
+			continue
+		}
+
+		var document map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(output), &document); err != nil {
+			t.Errorf("Expected a single json document got %s %s", output, err.Error())
+			continue
+		}
+
+		if len(document) != len(c.keys) {
+			t.Errorf("Expected keys %v got %s", c.keys, output)
+		}
+		for _, key := range c.keys {
+			if _, ok := document[key]; !ok {
+				t.Errorf("Expected key %s got %s", key, output)
+			}
+		}
+
+		var results []Result
+		if err := json.Unmarshal(document["Results"], &results); err != nil || len(results) != 3 {
+			t.Errorf("Expected 3 results got %s", document["Results"])
+		}
+	}
+}
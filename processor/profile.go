@@ -0,0 +1,70 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// CPUProfile is the file a CPU profile is written to
+var CPUProfile = ""
+
+// MemProfile is the file a heap profile is written to once finished
+var MemProfile = ""
+
+var cpuProfileFile *os.File
+
+// StartProfiles starts the CPU profile if one was requested
+func StartProfiles() {
+	if CPUProfile == "" {
+		return
+	}
+
+	file, err := os.Create(CPUProfile)
+	if err != nil {
+		printError(fmt.Sprintf("unable to create cpu profile: %s %s", CPUProfile, err.Error()))
+		return
+	}
+
+	if err := pprof.StartCPUProfile(file); err != nil {
+		printError(fmt.Sprintf("unable to start cpu profile: %s", err.Error()))
+		_ = file.Close()
+		return
+	}
+
+	cpuProfileFile = file
+}
+
+// Exits with the code making sure the profiles are written first as cobra's
+// PersistentPostRun and any deferred functions never run once os.Exit is called
+func exit(code int) {
+	StopProfiles()
+	os.Exit(code)
+}
+
+// StopProfiles stops the CPU profile and writes the heap profile if they were requested
+func StopProfiles() {
+	if cpuProfileFile != nil {
+		pprof.StopCPUProfile()
+		_ = cpuProfileFile.Close()
+		cpuProfileFile = nil
+	}
+
+	if MemProfile == "" {
+		return
+	}
+
+	file, err := os.Create(MemProfile)
+	if err != nil {
+		printError(fmt.Sprintf("unable to create memory profile: %s %s", MemProfile, err.Error()))
+		return
+	}
+	defer file.Close()
+
+	// Get up to date statistics on what is still in use
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(file); err != nil {
+		printError(fmt.Sprintf("unable to write memory profile: %s", err.Error()))
+	}
+}
@@ -0,0 +1,30 @@
+package processor
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	cpu, _ := ioutil.TempFile("", "hashit-cpu")
+	mem, _ := ioutil.TempFile("", "hashit-mem")
+	_ = cpu.Close()
+	_ = mem.Close()
+	defer os.Remove(cpu.Name())
+	defer os.Remove(mem.Name())
+
+	CPUProfile, MemProfile = cpu.Name(), mem.Name()
+	defer func() {
+		CPUProfile, MemProfile = "", ""
+	}()
+
+	StartProfiles()
+	StopProfiles()
+
+	for _, name := range []string{cpu.Name(), mem.Name()} {
+		if fi, err := os.Stat(name); err != nil || fi.Size() == 0 {
+			t.Errorf("Expected profile %s to be written", name)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counters updated as files are processed which are always kept so a
+// status line can be printed on request even without progress enabled
+var progressBytes int64
+var progressFiles int64
+var progressTotalBytes int64
+var progressTotalFiles int64
+var progressScanned int32
+var progressCurrent atomic.Value
+var progressStart time.Time
+
+var progressStop chan bool
+var progressDone sync.WaitGroup
+
+// How often the progress line is redrawn on a terminal and printed otherwise
+var progressTerminalInterval = 200 * time.Millisecond
+var progressPlainInterval = 10 * time.Second
+
+// Records bytes which count towards progress without being read such as cached files
+func progressSkip(n int64) {
+	atomic.AddInt64(&progressBytes, n)
+}
+
+// Records the file currently being processed
+func progressFile(filename string) {
+	progressCurrent.Store(filename)
+}
+
+// Sits between the workers and the formatter counting finished results
+func progressCollector(input chan Result) chan Result {
+	output := make(chan Result, FileListQueueSize)
+
+	go func() {
+		for res := range input {
+			atomic.AddInt64(&progressFiles, 1)
+			atomic.AddInt64(&summaryBytes, res.Bytes)
+			output <- res
+		}
+		close(output)
+	}()
+
+	return output
+}
+
+// Walks the paths totalling the files and bytes so the percentage and ETA
+// can be shown, runs at the same time as the hashing so nothing waits on it
+func progressPrescan(paths []string) {
+	queue := make(chan string, FileListQueueSize)
+
+	go func() {
+		for _, p := range paths {
+			fi, err := os.Stat(p)
+			if err != nil {
+				continue
+			}
+			if fi.IsDir() {
+				if Recursive {
+					scanDirectory(p, queue)
+				}
+			} else {
+				queue <- p
+			}
+		}
+		close(queue)
+	}()
+
+	for p := range queue {
+		if fi, err := os.Stat(p); err == nil {
+			atomic.AddInt64(&progressTotalBytes, fi.Size())
+			atomic.AddInt64(&progressTotalFiles, 1)
+		}
+	}
+
+	atomic.StoreInt32(&progressScanned, 1)
+}
+
+func formatBytes(n int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	value := float64(n)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value = value / 1024
+		i++
+	}
+
+	if i == 0 {
+		return fmt.Sprintf("%d B", n)
+	}
+	return fmt.Sprintf("%.1f %s", value, units[i])
+}
+
+// Builds the status line from the current counters
+func progressLine() string {
+	done := atomic.LoadInt64(&progressBytes)
+	files := atomic.LoadInt64(&progressFiles)
+	elapsed := time.Since(progressStart).Seconds()
+
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(done) / elapsed
+	}
+
+	var str strings.Builder
+	if atomic.LoadInt32(&progressScanned) == 1 {
+		total := atomic.LoadInt64(&progressTotalBytes)
+		percent := 100.0
+		if total != 0 {
+			percent = float64(done) / float64(total) * 100
+		}
+
+		str.WriteString(fmt.Sprintf("hashed %s of %s (%.1f%%) files %d/%d %s/s", formatBytes(done), formatBytes(total), percent, files, atomic.LoadInt64(&progressTotalFiles), formatBytes(int64(rate))))
+		if rate > 0 && total > done {
+			eta := time.Duration(float64(total-done) / rate * float64(time.Second))
+			str.WriteString(fmt.Sprintf(" ETA %s", eta.Round(time.Second)))
+		}
+	} else {
+		str.WriteString(fmt.Sprintf("hashed %s files %d %s/s", formatBytes(done), files, formatBytes(int64(rate))))
+		if Prescan {
+			str.WriteString(" scanning")
+		}
+	}
+
+	if current, ok := progressCurrent.Load().(string); ok && current != "" {
+		str.WriteString(" " + current)
+	}
+
+	return str.String()
+}
+
+// Starts drawing progress if enabled and listening for the status signal.
+// On a terminal the line is redrawn in place otherwise a line is printed
+// periodically so logs are not filled with updates
+func startProgress(paths []string) {
+	progressStart = time.Now()
+	progressStop = make(chan bool)
+
+	if Progress && Prescan && len(paths) != 0 {
+		go progressPrescan(paths)
+	}
+
+	stat, _ := os.Stderr.Stat()
+	terminal := stat != nil && (stat.Mode()&os.ModeCharDevice) != 0
+
+	interval := progressPlainInterval
+	if terminal {
+		interval = progressTerminalInterval
+	}
+
+	signals := make(chan os.Signal, 1)
+	if len(statusSignals) != 0 {
+		signal.Notify(signals, statusSignals...)
+	}
+
+	progressDone.Add(1)
+	go func() {
+		defer progressDone.Done()
+		defer signal.Stop(signals)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-progressStop:
+				if Progress && terminal {
+					// Clear the line so the output is left as it would be without progress
+					fmt.Fprint(os.Stderr, "\r\033[K")
+				}
+				return
+			case <-signals:
+				if Progress && terminal {
+					fmt.Fprint(os.Stderr, "\r\033[K")
+				}
+				fmt.Fprintln(os.Stderr, progressLine())
+			case <-ticker.C:
+				if !Progress {
+					continue
+				}
+
+				if terminal {
+					line := progressLine()
+					// Keep to a single line so it can be redrawn
+					if len(line) > 200 {
+						line = line[:200]
+					}
+					fmt.Fprint(os.Stderr, "\r\033[K"+line)
+				} else {
+					fmt.Fprintln(os.Stderr, progressLine())
+				}
+			}
+		}
+	}()
+}
+
+func stopProgress() {
+	if progressStop == nil {
+		return
+	}
+
+	close(progressStop)
+	progressDone.Wait()
+	progressStop = nil
+}
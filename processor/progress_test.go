@@ -0,0 +1,110 @@
+package processor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Clears the progress counters returning a function which restores them
+func resetProgress() func() {
+	bytes, files := progressBytes, progressFiles
+	totalBytes, totalFiles, scanned := progressTotalBytes, progressTotalFiles, progressScanned
+	start := progressStart
+
+	progressBytes, progressFiles = 0, 0
+	progressTotalBytes, progressTotalFiles, progressScanned = 0, 0, 0
+	progressCurrent.Store("")
+
+	return func() {
+		progressBytes, progressFiles = bytes, files
+		progressTotalBytes, progressTotalFiles, progressScanned = totalBytes, totalFiles, scanned
+		progressStart = start
+		progressCurrent.Store("")
+	}
+}
+
+func TestProgressPrescanTotals(t *testing.T) {
+	defer resetProgress()()
+
+	dir, err := ioutil.TempDir("", "hashit-progress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	_ = os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(dir, "a"), make([]byte, 100), 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "sub", "b"), make([]byte, 250), 0644)
+	single := filepath.Join(dir, "c")
+	_ = ioutil.WriteFile(single, make([]byte, 7), 0644)
+
+	progressPrescan([]string{filepath.Join(dir, "sub"), single})
+
+	if atomic.LoadInt32(&progressScanned) != 1 {
+		t.Error("Expected prescan to be marked as finished")
+	}
+	if progressTotalFiles != 2 {
+		t.Errorf("Expected 2 files got %d", progressTotalFiles)
+	}
+	if progressTotalBytes != 257 {
+		t.Errorf("Expected 257 bytes got %d", progressTotalBytes)
+	}
+}
+
+func TestProgressLineETA(t *testing.T) {
+	defer resetProgress()()
+
+	progressStart = time.Now().Add(-10 * time.Second)
+	progressBytes = 1000
+	progressFiles = 1
+	progressTotalBytes = 3000
+	progressTotalFiles = 3
+	progressScanned = 1
+
+	line := progressLine()
+
+	if !strings.Contains(line, "(33.3%) files 1/3") {
+		t.Errorf("Expected (33.3%%) files 1/3 got %s", line)
+	}
+	if !strings.Contains(line, "ETA 20s") {
+		t.Errorf("Expected ETA 20s got %s", line)
+	}
+
+	// Nothing is left so there is no ETA to show
+	progressBytes = 3000
+	if line := progressLine(); strings.Contains(line, "ETA") {
+		t.Errorf("Expected no ETA got %s", line)
+	}
+}
+
+func TestStandardInputCountsProgress(t *testing.T) {
+	defer resetProgress()()
+
+	file, err := ioutil.TempFile("", "hashit-stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	_, _ = file.Write(make([]byte, 12345))
+	_, _ = file.Seek(0, 0)
+
+	previous := os.Stdin
+	os.Stdin = file
+	defer func() {
+		os.Stdin = previous
+		_ = file.Close()
+	}()
+
+	output := make(chan Result, 1)
+	processStandardInput(output)
+	<-output
+
+	if progressBytes != 12345 {
+		t.Errorf("Expected 12345 bytes read from stdin got %d", progressBytes)
+	}
+}
@@ -0,0 +1,87 @@
+package processor
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Bytes per second parsed from MaxRate, 0 is unlimited
+var maxRateBytes int64
+
+// The time at which the next read is allowed to happen, pushed forward by
+// every read so the average rate across all readers stays under the limit
+var rateNext time.Time
+var rateMutex = sync.Mutex{}
+
+// Parses a size such as 1048576, 512K, 100M or 2G using powers of 1024
+func parseSize(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+
+	multiplier := int64(1)
+	if s != "" {
+		switch s[len(s)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier != 1 {
+			s = s[:len(s)-1]
+		}
+	}
+
+	// ParseFloat accepts nan, inf and values such as 1e400 none of which are a size
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(value) || value < 0 || value*float64(multiplier) >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %s", size)
+	}
+
+	return int64(value * float64(multiplier)), nil
+}
+
+// Every read from disk is reported here so that the rate limit applies across
+// all readers and the progress knows how much has been read
+func accountRead(n int64) {
+	if n <= 0 {
+		return
+	}
+
+	atomic.AddInt64(&progressBytes, n)
+
+	if maxRateBytes <= 0 {
+		return
+	}
+
+	rateMutex.Lock()
+	now := time.Now()
+	if rateNext.Before(now) {
+		rateNext = now
+	}
+	rateNext = rateNext.Add(time.Duration(float64(n) / float64(maxRateBytes) * float64(time.Second)))
+	wait := rateNext.Sub(now)
+	rateMutex.Unlock()
+
+	time.Sleep(wait)
+}
+
+// Wraps a reader so everything read through it is accounted for
+type meteredReader struct {
+	reader io.Reader
+}
+
+func (m meteredReader) Read(p []byte) (int, error) {
+	n, err := m.reader.Read(p)
+	accountRead(int64(n))
+	return n, err
+}
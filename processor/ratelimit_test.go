@@ -0,0 +1,31 @@
+package processor
+
+import (
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	sizes := map[string]int64{
+		"1048576": 1048576,
+		"512K":    524288,
+		"100M":    104857600,
+		"1.5G":    1610612736,
+		"2GiB":    2147483648,
+	}
+
+	for size, expected := range sizes {
+		res, err := parseSize(size)
+		if err != nil {
+			t.Errorf("Expected no error for %s got %s", size, err.Error())
+		}
+		if res != expected {
+			t.Errorf("Expected %d for %s got %d", expected, size, res)
+		}
+	}
+
+	for _, size := range []string{"fast", "nan", "NaN", "inf", "+Inf", "-inf", "1e400", "-1", "1e19", "8388608T"} {
+		if _, err := parseSize(size); err == nil {
+			t.Errorf("Expected error for %s", size)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package processor
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// Region parsed from Offset and Length, a length of -1 is to the end
+var offsetBytes int64
+var lengthBytes int64 = -1
+
+// Parses the region to hash so any mistakes are reported before processing starts
+func parseRegion() error {
+	offsetBytes, lengthBytes = 0, -1
+
+	var err error
+	if Offset != "" {
+		if offsetBytes, err = parseSize(Offset); err != nil {
+			return fmt.Errorf("offset %s", err.Error())
+		}
+	}
+	if Length != "" {
+		if lengthBytes, err = parseSize(Length); err != nil {
+			return fmt.Errorf("length %s", err.Error())
+		}
+	}
+
+	if SectorSize < 0 || SectorSize&(SectorSize-1) != 0 {
+		return fmt.Errorf("sector-size must be a power of two got %d", SectorSize)
+	}
+
+	if hasRegion() && (Archives || Decompress != "") {
+		return fmt.Errorf("offset and length cannot be used with archives or decompress")
+	}
+
+	return nil
+}
+
+// Check if only part of each file is to be hashed
+func hasRegion() bool {
+	return offsetBytes != 0 || lengthBytes >= 0
+}
+
+// Block devices report a size of 0 when stat is called on them
+func isBlockDevice(fi os.FileInfo) bool {
+	return fi.Mode()&os.ModeDevice != 0 && fi.Mode()&os.ModeCharDevice == 0
+}
+
+// Check if the file needs to be read using processRegion rather than any of the usual ways
+func needsRegion(fi os.FileInfo) bool {
+	return hasRegion() || SectorSize > 0 || isBlockDevice(fi)
+}
+
+// Name used to report the hashes of part of a file so they cannot be confused
+// with the hashes of the whole file
+func regionName(filename string, length int64) string {
+	if !hasRegion() {
+		return filename
+	}
+	if length < 0 {
+		return fmt.Sprintf("%s (offset %d)", filename, offsetBytes)
+	}
+	return fmt.Sprintf("%s (offset %d length %d)", filename, offsetBytes, length)
+}
+
+// Works out the size of the file, seeking to the end for block devices,
+// returning -1 if the size cannot be known such as for character devices
+func regionFileSize(file *os.File, fi os.FileInfo) int64 {
+	if fi.Mode().IsRegular() {
+		return fi.Size()
+	}
+
+	if isBlockDevice(fi) {
+		size, err := file.Seek(0, io.SeekEnd)
+		if err == nil {
+			if _, err := file.Seek(0, io.SeekStart); err == nil {
+				return size
+			}
+		}
+	}
+
+	return -1
+}
+
+// Streams the region of the file to be hashed, used for block devices which
+// cannot be memory mapped or read in one go and when reads need to be aligned
+func processRegion(filename string, file *os.File, fi os.FileInfo) (Result, error) {
+	size := regionFileSize(file, fi)
+
+	length := lengthBytes
+	if size >= 0 {
+		if offsetBytes > size {
+			return Result{}, fmt.Errorf("offset %d is beyond the end of %s at %d", offsetBytes, filename, size)
+		}
+		if length < 0 {
+			length = size - offsetBytes
+		}
+		if offsetBytes+length > size {
+			return Result{}, fmt.Errorf("offset %d length %d is beyond the end of %s at %d", offsetBytes, length, filename, size)
+		}
+	}
+
+	if Debug {
+		printDebug(fmt.Sprintf("%s bytes=%d offset=%d length=%d sector-size=%d using region", filename, size, offsetBytes, length, SectorSize))
+	}
+
+	var reader io.Reader
+	if SectorSize > 0 {
+		end := int64(-1)
+		if length >= 0 {
+			end = offsetBytes + length
+		}
+		reader = newAlignedReader(file, offsetBytes, end, int64(SectorSize))
+	} else {
+		if offsetBytes != 0 {
+			// Files which cannot seek such as character devices have the start read and thrown away
+			if _, err := file.Seek(offsetBytes, io.SeekStart); err != nil {
+				if _, err := io.CopyN(ioutil.Discard, file, offsetBytes); err != nil {
+					return Result{}, err
+				}
+			}
+		}
+		reader = file
+		if length >= 0 {
+			reader = io.LimitReader(file, length)
+		}
+	}
+
+	res, err := processStream(regionName(filename, length), meteredReader{reader})
+	if err != nil {
+		return res, err
+	}
+
+	if length >= 0 && res.Bytes != length {
+		return Result{}, fmt.Errorf("only read %d of %d bytes from %s", res.Bytes, length, filename)
+	}
+
+	return res, nil
+}
+
+// Reads from a file only at offsets and in sizes which are multiples of the sector size,
+// reading the whole sectors containing the start and end and returning just the region
+type alignedReader struct {
+	file   io.ReaderAt
+	pos    int64
+	end    int64
+	sector int64
+	buffer []byte
+	data   []byte
+}
+
+func newAlignedReader(file io.ReaderAt, start int64, end int64, sector int64) *alignedReader {
+	size := int64(streamBufferSize) - int64(streamBufferSize)%sector
+	if size < sector {
+		size = sector
+	}
+
+	return &alignedReader{
+		file:   file,
+		pos:    start,
+		end:    end,
+		sector: sector,
+		buffer: make([]byte, size),
+	}
+}
+
+func (a *alignedReader) Read(p []byte) (int, error) {
+	if len(a.data) == 0 {
+		if a.end >= 0 && a.pos >= a.end {
+			return 0, io.EOF
+		}
+
+		start := a.pos - a.pos%a.sector
+		n, err := a.file.ReadAt(a.buffer, start)
+		skip := int(a.pos - start)
+		if n <= skip {
+			if err == nil {
+				err = io.EOF
+			}
+			return 0, err
+		}
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		a.data = a.buffer[skip:n]
+		if a.end >= 0 && int64(len(a.data)) > a.end-a.pos {
+			a.data = a.data[:a.end-a.pos]
+		}
+	}
+
+	n := copy(p, a.data)
+	a.data = a.data[n:]
+	a.pos += int64(n)
+	return n, nil
+}
@@ -0,0 +1,29 @@
+package processor
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestAlignedReader(t *testing.T) {
+	content := make([]byte, 5000)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+
+	cases := [][2]int64{{0, 5000}, {1, 1024}, {511, 513}, {1000, 5000}, {4999, 5000}}
+	for _, c := range cases {
+		res, err := ioutil.ReadAll(newAlignedReader(bytes.NewReader(content), c[0], c[1], 512))
+		if err != nil {
+			t.Errorf("Expected no error for %d-%d got %s", c[0], c[1], err.Error())
+		} else if !bytes.Equal(res, content[c[0]:c[1]]) {
+			t.Errorf("Expected %d bytes for %d-%d got %d", c[1]-c[0], c[0], c[1], len(res))
+		}
+	}
+
+	res, _ := ioutil.ReadAll(newAlignedReader(bytes.NewReader(content), 700, -1, 512))
+	if !bytes.Equal(res, content[700:]) {
+		t.Errorf("Expected %d bytes to the end got %d", len(content)-700, len(res))
+	}
+}
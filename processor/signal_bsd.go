@@ -0,0 +1,12 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package processor
+
+import (
+	"os"
+	"syscall"
+)
+
+// Signals which print a status line, SIGINFO is sent by ctrl+t on BSD terminals
+var statusSignals = []os.Signal{syscall.SIGUSR1, syscall.SIGINFO}
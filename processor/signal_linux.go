@@ -0,0 +1,9 @@
+package processor
+
+import (
+	"os"
+	"syscall"
+)
+
+// Signals which print a status line
+var statusSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package processor
+
+import (
+	"os"
+)
+
+// There is no status signal on other platforms
+var statusSignals = []os.Signal{}
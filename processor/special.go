@@ -0,0 +1,64 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// FIFOs, sockets and devices can block forever or never end when read
+const specialMode = os.ModeNamedPipe | os.ModeSocket | os.ModeDevice | os.ModeCharDevice
+
+var skippedSpecial int64
+var skippedFilesystems int64
+var skippedDirectories int64
+
+// Records something the walker did not descend into or process so it can be reported
+func reportSkipped(counter *int64, path string, reason string) {
+	atomic.AddInt64(counter, 1)
+	recordSkipped(path, reason)
+	if Verbose {
+		printVerbose(fmt.Sprintf("skipping %s: %s", path, reason))
+	}
+}
+
+// Describes the kind of special file for reporting
+func specialKind(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		return "fifo"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeCharDevice != 0:
+		return "character device"
+	}
+	return "block device"
+}
+
+// Checks if walking into the directory would leave the filesystem of the directory above it
+// returning true if it should be skipped because of --one-file-system or it is /proc or similar
+func (w *walkFilter) crossesFilesystem(path string, fi os.FileInfo) bool {
+	if len(w.active) == 0 || w.active[len(w.active)-1].info == nil {
+		return false
+	}
+
+	if fileIdentity(fi).Device == fileIdentity(w.active[len(w.active)-1].info).Device {
+		return false
+	}
+
+	if OneFileSystem {
+		if w.record {
+			reportSkipped(&skippedFilesystems, path, "directory on another filesystem")
+		}
+		return true
+	}
+
+	if pseudoFilesystem(path) {
+		if w.record {
+			reportSkipped(&skippedFilesystems, path, "directory on a pseudo filesystem")
+		}
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,37 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package processor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestWalkSkipsSpecialFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hashit-special")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	_ = ioutil.WriteFile(filepath.Join(dir, "file"), []byte("hello"), 0644)
+	if err := syscall.Mkfifo(filepath.Join(dir, "fifo"), 0644); err != nil {
+		t.Skip("fifos not supported")
+	}
+
+	output := make(chan string, 10)
+	walkDirectory(dir, output)
+	close(output)
+
+	if len(output) != 1 {
+		t.Errorf("Expected 1 file got %d", len(output))
+	}
+
+	if skippedSpecial == 0 {
+		t.Error("Expected the fifo to be reported as skipped")
+	}
+}
@@ -0,0 +1,81 @@
+//go:build linux
+// +build linux
+
+package processor
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Filesystem magic numbers from statfs for network filesystems
+var networkFilesystems = map[uint32]bool{
+	0x6969:     true, // nfs
+	0x517b:     true, // smb
+	0xff534d42: true, // cifs
+	0xfe534d42: true, // smb2
+	0x65735546: true, // fuse which is usually sshfs or similar
+	0x00c36400: true, // ceph
+}
+
+// Filesystem magic numbers from statfs for filesystems which are not real files
+var pseudoFilesystems = map[uint32]bool{
+	0x9fa0:     true, // proc
+	0x62656572: true, // sysfs
+	0x1cd1:     true, // devpts
+	0x64626720: true, // debugfs
+	0x74726163: true, // tracefs
+	0x73636673: true, // securityfs
+	0x27e0eb:   true, // cgroup
+	0x63677270: true, // cgroup2
+	0x6165676c: true, // pstore
+	0xcafe4a11: true, // bpf
+	0x62656570: true, // configfs
+	0xde5e81e4: true, // efivarfs
+}
+
+// Where the kind of storage is looked up, replaceable to test against fake devices
+var statfs = unix.Statfs
+var sysDevBlock = "/sys/dev/block"
+
+// Checks if the path is on a filesystem such as /proc or /sys which should never be walked
+func pseudoFilesystem(path string) bool {
+	var fs unix.Statfs_t
+	return statfs(path, &fs) == nil && pseudoFilesystems[uint32(fs.Type)]
+}
+
+// Works out the kind of storage the path lives on returning network,
+// rotational, solid or an empty string if it cannot be determined
+func storageKind(path string) string {
+	var fs unix.Statfs_t
+	if err := statfs(path, &fs); err == nil && networkFilesystems[uint32(fs.Type)] {
+		return "network"
+	}
+
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return ""
+	}
+
+	dev := uint64(st.Dev)
+	sys, err := filepath.EvalSymlinks(filepath.Join(sysDevBlock, fmt.Sprintf("%d:%d", unix.Major(dev), unix.Minor(dev))))
+	if err != nil {
+		return ""
+	}
+
+	// Partitions have no queue of their own so check the disk they belong to as well
+	for _, p := range []string{filepath.Join(sys, "queue", "rotational"), filepath.Join(sys, "..", "queue", "rotational")} {
+		content, err := ioutil.ReadFile(p)
+		if err == nil {
+			if strings.TrimSpace(string(content)) == "1" {
+				return "rotational"
+			}
+			return "solid"
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,69 @@
+//go:build linux
+// +build linux
+
+package processor
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStorageKindNetwork(t *testing.T) {
+	defer func() {
+		statfs = unix.Statfs
+	}()
+
+	statfs = func(path string, buf *unix.Statfs_t) error {
+		buf.Type = 0x6969
+		return nil
+	}
+
+	if kind := storageKind(os.TempDir()); kind != "network" {
+		t.Errorf("Expected network got %s", kind)
+	}
+}
+
+func TestStorageKindRotational(t *testing.T) {
+	previous := sysDevBlock
+	defer func() {
+		sysDevBlock = previous
+	}()
+
+	dir, err := ioutil.TempDir("", "hashit-storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var st unix.Stat_t
+	if err := unix.Stat(dir, &st); err != nil {
+		t.Fatal(err)
+	}
+	device := fmt.Sprintf("%d:%d", unix.Major(uint64(st.Dev)), unix.Minor(uint64(st.Dev)))
+
+	// Lay out a fake disk with a partition which has no queue of its own
+	disk := filepath.Join(dir, "devices", "sda")
+	_ = os.MkdirAll(filepath.Join(disk, "queue"), 0755)
+	_ = os.MkdirAll(filepath.Join(disk, "sda1"), 0755)
+	sysDevBlock = filepath.Join(dir, "block")
+	_ = os.MkdirAll(sysDevBlock, 0755)
+	_ = os.Symlink(filepath.Join(disk, "sda1"), filepath.Join(sysDevBlock, device))
+
+	if kind := storageKind(dir); kind != "" {
+		t.Errorf("Expected no kind without a queue got %s", kind)
+	}
+
+	_ = ioutil.WriteFile(filepath.Join(disk, "queue", "rotational"), []byte("1\n"), 0644)
+	if kind := storageKind(dir); kind != "rotational" {
+		t.Errorf("Expected rotational got %s", kind)
+	}
+
+	_ = ioutil.WriteFile(filepath.Join(disk, "queue", "rotational"), []byte("0\n"), 0644)
+	if kind := storageKind(dir); kind != "solid" {
+		t.Errorf("Expected solid got %s", kind)
+	}
+}
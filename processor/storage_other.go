@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package processor
+
+// Storage detection is only implemented for Linux so everything else uses the defaults
+func storageKind(path string) string {
+	return ""
+}
+
+// Pseudo filesystems are only detected on Linux
+func pseudoFilesystem(path string) bool {
+	return false
+}
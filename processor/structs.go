@@ -14,9 +14,22 @@ type Result struct {
 	Sha3256     string
 	Sha3384     string
 	Sha3512     string
+	AHash       string `json:",omitempty"`
+	DHash       string `json:",omitempty"`
+	PHash       string `json:",omitempty"`
 	Bytes       int64
 	Description string
 	Version     string
 	Date        string
 	Urls        []string
+	Chunks      []Chunk `json:",omitempty"`
+	HardlinkOf  string  `json:",omitempty"`
+	Symlink     string  `json:",omitempty"`
+}
+
+// Content defined chunk of a file used for dedup analysis
+type Chunk struct {
+	Offset int64
+	Length int64
+	SHA256 string
 }
@@ -0,0 +1,161 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A path which could not be processed or was skipped along with why
+type runIssue struct {
+	File    string
+	Error   string `json:",omitempty"`
+	Skipped string `json:",omitempty"`
+}
+
+var runErrors = []runIssue{}
+var runSkipped = []runIssue{}
+var runMutex = sync.Mutex{}
+
+// When the run started for the elapsed time and throughput
+var summaryStart = time.Now()
+
+// Bytes in every result which was output
+var summaryBytes int64
+
+// Reports a path which could not be processed and remembers it for the summary and exit code
+func recordError(file string, msg string) {
+	printError(msg)
+
+	runMutex.Lock()
+	runErrors = append(runErrors, runIssue{File: file, Error: msg})
+	runMutex.Unlock()
+}
+
+// Remembers a path which was skipped for the summary
+func recordSkipped(file string, reason string) {
+	runMutex.Lock()
+	runSkipped = append(runSkipped, runIssue{File: file, Skipped: reason})
+	runMutex.Unlock()
+}
+
+// Number of paths which could not be processed
+func errorCount() int {
+	runMutex.Lock()
+	defer runMutex.Unlock()
+	return len(runErrors)
+}
+
+// Checks if the output format is one of the json ones
+func jsonFormat() bool {
+	f := strings.ToLower(Format)
+	return f == "json" || f == "ndjson"
+}
+
+// Paths are recorded in whatever order the workers reach them so they are
+// sorted by path when the output needs to be reproducible
+func orderedIssues(issues []runIssue) []runIssue {
+	if !Reproducible {
+		return issues
+	}
+
+	sorted := append([]runIssue{}, issues...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].File < sorted[j].File
+	})
+	return sorted
+}
+
+// Every error and skipped path as a json record each on its own for the json formats
+func issueRecords() [][]byte {
+	runMutex.Lock()
+	defer runMutex.Unlock()
+
+	records := [][]byte{}
+	for _, issues := range [][]runIssue{runErrors, runSkipped} {
+		for _, i := range orderedIssues(issues) {
+			jsonString, _ := json.Marshal(i)
+			records = append(records, jsonString)
+		}
+	}
+
+	return records
+}
+
+// Prints the number of paths which failed or were skipped to stderr so it does not mix with the results
+func printRunIssues() {
+	special := atomic.LoadInt64(&skippedSpecial)
+	filesystems := atomic.LoadInt64(&skippedFilesystems)
+	directories := atomic.LoadInt64(&skippedDirectories)
+
+	if special != 0 || filesystems != 0 {
+		fmt.Fprintf(os.Stderr, "skipped %d special files and %d directories on other or pseudo filesystems\n", special, filesystems)
+	}
+	if directories != 0 {
+		fmt.Fprintf(os.Stderr, "skipped %d directories as --no-recursive is set\n", directories)
+	}
+	if errors := errorCount(); errors != 0 {
+		fmt.Fprintf(os.Stderr, "%d files or directories could not be processed\n", errors)
+	}
+}
+
+// Formats the totals for the run along with every path which failed or was
+// skipped to match the output format
+func toSummary() string {
+	runMutex.Lock()
+	defer runMutex.Unlock()
+
+	files := atomic.LoadInt64(&progressFiles)
+	bytes := atomic.LoadInt64(&summaryBytes)
+	elapsed := time.Since(summaryStart).Seconds()
+
+	throughput := 0.0
+	if elapsed > 0 {
+		throughput = float64(bytes) / elapsed
+	}
+
+	// Timings differ on every run so they are left out of reproducible output
+	var elapsedSeconds, bytesPerSecond *float64
+	if !Reproducible {
+		elapsedSeconds, bytesPerSecond = &elapsed, &throughput
+	}
+
+	// The json formats already have a record for every path which failed or was skipped
+	if jsonFormat() {
+		jsonString, _ := json.Marshal(struct {
+			Files          int64
+			Bytes          int64
+			ElapsedSeconds *float64 `json:",omitempty"`
+			BytesPerSecond *float64 `json:",omitempty"`
+			Errors         int
+			Skipped        int
+		}{files, bytes, elapsedSeconds, bytesPerSecond, len(runErrors), len(runSkipped)})
+		return string(jsonString) + "\n"
+	}
+
+	prefix := footerPrefix()
+
+	var str strings.Builder
+	str.WriteString(fmt.Sprintf("%ssummary\n", prefix))
+	str.WriteString(fmt.Sprintf("%s      files %d\n", prefix, files))
+	str.WriteString(fmt.Sprintf("%s      bytes %d (%s)\n", prefix, bytes, formatBytes(bytes)))
+	if !Reproducible {
+		str.WriteString(fmt.Sprintf("%s    elapsed %.3fs\n", prefix, elapsed))
+		str.WriteString(fmt.Sprintf("%s throughput %s/s\n", prefix, formatBytes(int64(throughput))))
+	}
+	str.WriteString(fmt.Sprintf("%s     errors %d\n", prefix, len(runErrors)))
+	for _, i := range orderedIssues(runErrors) {
+		str.WriteString(fmt.Sprintf("%s       %s\n", prefix, i.Error))
+	}
+	str.WriteString(fmt.Sprintf("%s    skipped %d\n", prefix, len(runSkipped)))
+	for _, i := range orderedIssues(runSkipped) {
+		str.WriteString(fmt.Sprintf("%s       %s: %s\n", prefix, i.File, i.Skipped))
+	}
+
+	return str.String()
+}
@@ -0,0 +1,128 @@
+package processor
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestIssueRecords(t *testing.T) {
+	runErrors = []runIssue{}
+	runSkipped = []runIssue{}
+	defer func() {
+		runErrors = []runIssue{}
+		runSkipped = []runIssue{}
+	}()
+
+	recordError("missing", "unable to open missing")
+	recordSkipped("fifo", "fifo")
+
+	records := issueRecords()
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records got %d", len(records))
+	}
+
+	if string(records[0]) != `{"File":"missing","Error":"unable to open missing"}` {
+		t.Errorf("Expected error record got %s", records[0])
+	}
+
+	if string(records[1]) != `{"File":"fifo","Skipped":"fifo"}` {
+		t.Errorf("Expected skipped record got %s", records[1])
+	}
+
+	if errorCount() != 1 {
+		t.Errorf("Expected 1 error got %d", errorCount())
+	}
+}
+
+func TestErrorsRecordedOnce(t *testing.T) {
+	runErrors = []runIssue{}
+	defer func() {
+		runErrors = []runIssue{}
+		Offset = ""
+		_ = parseRegion()
+	}()
+
+	dir, err := ioutil.TempDir("", "hashit-summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A stored member can be corrupted without breaking the rest of the zip
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	w, _ := z.CreateHeader(&zip.FileHeader{Name: "member", Method: zip.Store})
+	_, _ = w.Write([]byte("hello world"))
+	_ = z.Close()
+
+	content := bytes.Replace(buf.Bytes(), []byte("hello world"), []byte("hello there"), 1)
+	zipfile := filepath.Join(dir, "corrupt.zip")
+	_ = ioutil.WriteFile(zipfile, content, 0644)
+
+	output := make(chan Result, 10)
+	processArchive(zipfile, output)
+	if errorCount() != 1 {
+		t.Errorf("Expected 1 error for crc mismatch got %d", errorCount())
+	}
+
+	small := filepath.Join(dir, "small")
+	_ = ioutil.WriteFile(small, []byte("small"), 0644)
+	Offset = "1M"
+	_ = parseRegion()
+
+	var pending sync.WaitGroup
+	processFile(fileJob{path: small, results: output}, &pending)
+	if errorCount() != 2 {
+		t.Errorf("Expected 2 errors after offset beyond the end got %d", errorCount())
+	}
+
+	if len(output) != 0 {
+		t.Errorf("Expected no results got %d", len(output))
+	}
+}
+
+func TestJSONIssuesKeptApartFromResults(t *testing.T) {
+	runErrors = []runIssue{}
+	defer func() {
+		Format, NoStream, Summary = "", false, false
+		runErrors = []runIssue{}
+	}()
+
+	Format = "json"
+	NoStream = true
+	recordError("missing", "unable to open missing")
+
+	input := make(chan Result, 1)
+	input <- Result{File: "a", MD5: "60b725f10c9c85c70d97880dfe8191b3"}
+	close(input)
+
+	var results []Result
+	output := toJSON(input)
+	if err := json.Unmarshal([]byte(output), &results); err != nil || len(results) != 1 || results[0].File != "a" {
+		t.Errorf("Expected only the result in the array got %s", output)
+	}
+
+	Summary = true
+	input = make(chan Result, 1)
+	input <- Result{File: "a", MD5: "60b725f10c9c85c70d97880dfe8191b3"}
+	close(input)
+	output = appendFooters(toJSON(input), []runFooter{{"Summary", toSummary()}})
+
+	var document struct {
+		Results []Result
+		Issues  []runIssue
+		Summary struct{ Errors int }
+	}
+	if err := json.Unmarshal([]byte(output), &document); err != nil {
+		t.Fatalf("Expected a single json document got %s", output)
+	}
+	if len(document.Results) != 1 || len(document.Issues) != 1 || document.Issues[0].File != "missing" || document.Summary.Errors != 1 {
+		t.Errorf("Expected 1 result and 1 issue got %s", output)
+	}
+}
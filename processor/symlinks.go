@@ -0,0 +1,91 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+)
+
+// Check if links to files are hashed as the file they point to
+func followSymlinks() bool {
+	return Symlinks == "files" || Symlinks == "follow"
+}
+
+// Decides what to do with a symlink found while walking returning if it should be
+// walked or processed as a file, and the type of its target
+func (w *walkFilter) symlink(path string) (bool, os.FileMode) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		// Broken links are left for the worker to report when following
+		return followSymlinks(), 0
+	}
+
+	if Symlinks == "files" {
+		if fi.IsDir() {
+			if Debug {
+				printDebug(fmt.Sprintf("not walking symlinked directory %s", path))
+			}
+			return false, os.ModeDir
+		}
+		return true, fi.Mode() & os.ModeType
+	}
+
+	if Symlinks != "follow" {
+		if Debug && Symlinks == "skip" {
+			printDebug(fmt.Sprintf("skipping symlink %s", path))
+		}
+		return false, fi.Mode() & os.ModeType
+	}
+
+	if fi.IsDir() {
+		for _, dir := range w.active {
+			if dir.info != nil && os.SameFile(dir.info, fi) {
+				if Verbose {
+					printVerbose(fmt.Sprintf("skipping symlink %s which loops back to a directory above it", path))
+				}
+				return false, os.ModeDir
+			}
+		}
+	}
+
+	return true, fi.Mode() & os.ModeType
+}
+
+// Hashes the target of the symlink rather than what it points to so that a
+// file being swapped for a link is noticed
+func processSymlink(filename string, output chan Result) {
+	target, err := os.Readlink(filename)
+	if err != nil {
+		recordError(filename, fmt.Sprintf("Unable to read symlink %s with error %s", filename, err.Error()))
+		return
+	}
+
+	content := []byte(target)
+	r, err := processContent(filename, int64(len(content)), &content)
+	if err != nil {
+		return
+	}
+
+	r.Symlink = target
+	output <- r
+}
+
+// Checks if the file is a symlink which should not be followed and if so skips
+// or records it returning true if it was handled
+func handleSymlink(filename string, output chan Result) bool {
+	if followSymlinks() {
+		return false
+	}
+
+	fi, err := os.Lstat(filename)
+	if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		return false
+	}
+
+	if Symlinks == "record" {
+		processSymlink(filename, output)
+	} else if Debug {
+		printDebug(fmt.Sprintf("skipping symlink %s", filename))
+	}
+
+	return true
+}
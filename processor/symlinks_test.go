@@ -0,0 +1,72 @@
+package processor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWalkSymlinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hashit-symlinks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	_ = os.MkdirAll(filepath.Join(dir, "a", "b"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(dir, "a", "file"), []byte("hello"), 0644)
+	if err := os.Symlink("file", filepath.Join(dir, "a", "link")); err != nil {
+		t.Skip("symlinks not supported")
+	}
+	_ = os.Symlink("..", filepath.Join(dir, "a", "b", "loop"))
+	_ = os.MkdirAll(filepath.Join(dir, "e"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(dir, "e", "inner"), []byte("inner"), 0644)
+	_ = os.Symlink(filepath.Join("..", "e"), filepath.Join(dir, "a", "dirlink"))
+
+	// Linked directories are only walked when following every link
+	defer func() { Symlinks = "files" }()
+	expected := map[string]int{"files": 3, "follow": 4, "skip": 2, "record": 5}
+
+	for mode, count := range expected {
+		Symlinks = mode
+		output := make(chan string, 10)
+		walkDirectory(dir, output)
+		close(output)
+
+		if len(output) != count {
+			t.Errorf("Expected %d files for %s got %d", count, mode, len(output))
+		}
+	}
+}
+
+func TestRecordedSymlinkSumName(t *testing.T) {
+	dir := processTestDir(t)
+	defer os.RemoveAll(dir)
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink("a", link); err != nil {
+		t.Skip("symlinks not supported")
+	}
+	defer func() {
+		Symlinks = "files"
+		resetAudit()
+	}()
+
+	// The target is only ever in a comment so the name can be checked and audited
+	Symlinks = "record"
+	Hash = []string{HashNames.MD5}
+	Format = "sum"
+	output := processOutput(t, link)
+	expected := "## symlink " + link + " -> a\n0cc175b9c0f1b6a831c399e269772661  " + link + "\n"
+	if output != expected {
+		t.Errorf("Expected %q got %q", expected, output)
+	}
+
+	manifest := filepath.Join(dir, "manifest")
+	_ = ioutil.WriteFile(manifest, []byte(output), 0644)
+	AuditFile = manifest
+	if output := processOutput(t, link); !strings.HasPrefix(output, "audit passed\n") {
+		t.Errorf("Expected audit passed got %s", output)
+	}
+}
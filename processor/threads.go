@@ -0,0 +1,72 @@
+package processor
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Limits how many goroutines are hashing at once across every file being read
+var hashSlots = make(chan struct{}, runtime.NumCPU())
+
+// Limits how many files can be held in memory waiting to be hashed so that
+// readers can move onto the next file without memory growing without bound
+var pendingFiles = make(chan struct{}, runtime.NumCPU())
+
+func acquireHashThread() {
+	hashSlots <- struct{}{}
+}
+
+func releaseHashThread() {
+	<-hashSlots
+}
+
+// Sets up the hashing limits, called before any files are processed
+func setupHashThreads() {
+	threads := HashThreads
+	if threads <= 0 {
+		threads = runtime.NumCPU()
+	}
+
+	hashSlots = make(chan struct{}, threads)
+	pendingFiles = make(chan struct{}, threads)
+
+	if Verbose {
+		printVerbose(fmt.Sprintf("using %d hash threads", threads))
+	}
+}
+
+// Works out the kind of storage a path lives on, replaceable so the thread
+// counts can be checked without the storage itself
+var storageKindOf = storageKind
+
+// Number of files to read at once, if not set it depends on the storage the
+// paths live on as many readers cause seek storms on spinning disks and
+// network filesystems while fast solid state storage benefits from them
+func ioThreads(paths []string) int {
+	if IOThreads > 0 {
+		return IOThreads
+	}
+
+	threads := runtime.NumCPU()
+	for _, p := range paths {
+		kind := storageKindOf(p)
+		if Debug {
+			printDebug(fmt.Sprintf("%s storage kind %q", p, kind))
+		}
+
+		switch kind {
+		case "rotational":
+			threads = 1
+		case "network":
+			if threads > 4 {
+				threads = 4
+			}
+		}
+	}
+
+	if Verbose {
+		printVerbose(fmt.Sprintf("using %d io threads", threads))
+	}
+
+	return threads
+}
@@ -0,0 +1,53 @@
+package processor
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestIOThreads(t *testing.T) {
+	previous := storageKindOf
+	defer func() {
+		storageKindOf = previous
+		IOThreads = 0
+	}()
+
+	kinds := map[string]string{
+		"ssd":     "solid",
+		"unknown": "",
+		"disk":    "rotational",
+		"nfs":     "network",
+	}
+	storageKindOf = func(path string) string {
+		return kinds[path]
+	}
+
+	network := runtime.NumCPU()
+	if network > 4 {
+		network = 4
+	}
+
+	cases := []struct {
+		paths    []string
+		expected int
+	}{
+		{[]string{"ssd"}, runtime.NumCPU()},
+		{[]string{"unknown"}, runtime.NumCPU()},
+		{[]string{"nfs"}, network},
+		{[]string{"ssd", "disk"}, 1},
+		{[]string{"disk", "nfs"}, 1},
+		{[]string{"nfs", "ssd"}, network},
+	}
+
+	for _, c := range cases {
+		if res := ioThreads(c.paths); res != c.expected {
+			t.Errorf("Expected %d for %v got %d", c.expected, c.paths, res)
+		}
+	}
+
+	// Setting the threads skips looking at the storage entirely
+	IOThreads = 3
+	if res := ioThreads([]string{"disk"}); res != 3 {
+		t.Errorf("Expected 3 got %d", res)
+	}
+}
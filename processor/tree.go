@@ -0,0 +1,211 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Single entry in the tree manifest which is either a file or an empty directory
+type treeEntry struct {
+	Path   string
+	Size   int64
+	Mode   string
+	Digest string
+	Dir    bool
+}
+
+var treeEntries = []treeEntry{}
+var treeMutex = sync.Mutex{}
+
+// Called by the walker for any directory which has no children so it can be
+// included in the tree digest if requested
+func recordEmptyDirectory(path string) {
+	treeMutex.Lock()
+	treeEntries = append(treeEntries, treeEntry{
+		Path: treeManifestPath(path),
+		Dir:  true,
+	})
+	treeMutex.Unlock()
+}
+
+// Sits between the workers and the formatter recording every result so that
+// the tree digest can be calculated once everything has been processed
+func treeHashCollector(input chan Result) chan Result {
+	output := make(chan Result, FileListQueueSize)
+
+	go func() {
+		for res := range input {
+			entry := treeEntry{
+				Path:   treeManifestPath(res.File),
+				Size:   res.Bytes,
+				Digest: res.SHA256,
+			}
+
+			if TreeHashModes {
+				fi, err := os.Lstat(res.File)
+				if err == nil {
+					entry.Mode = fi.Mode().String()
+				}
+			}
+
+			treeMutex.Lock()
+			treeEntries = append(treeEntries, entry)
+			treeMutex.Unlock()
+
+			// The SHA256 is only shown if it was asked for and not just calculated for the digest
+			if !hasHash(HashNames.SHA256) {
+				res.SHA256 = ""
+			}
+
+			output <- res
+		}
+		close(output)
+	}()
+
+	return output
+}
+
+// Finds the argument the file was found under returning it along with the path of the
+// file relative to it, the root is empty if the file was not found under any of them
+func treeRoot(file string) (string, string) {
+	for _, p := range DirFilePaths {
+		root := filepath.Clean(p)
+
+		if file == root {
+			return root, filepath.ToSlash(filepath.Base(file))
+		}
+
+		if root == "." && !filepath.IsAbs(file) {
+			return root, filepath.ToSlash(file)
+		}
+
+		// The root of the filesystem already ends with a separator
+		prefix := strings.TrimSuffix(root, string(os.PathSeparator)) + string(os.PathSeparator)
+		if strings.HasPrefix(file, prefix) {
+			return root, filepath.ToSlash(strings.TrimPrefix(file, prefix))
+		}
+	}
+
+	return "", filepath.ToSlash(file)
+}
+
+// Works out the path of the file relative to the argument it was found under
+// so the digest does not change when the tree is moved somewhere else
+func treeRelativePath(file string) string {
+	_, rel := treeRoot(file)
+	return rel
+}
+
+// Works out the path used in the manifest which is relative to the argument the file was
+// found under. With more than one argument it starts with that argument so the same
+// relative path under each of them cannot collide
+func treeManifestPath(file string) string {
+	root, rel := treeRoot(file)
+	if len(DirFilePaths) < 2 || root == "" {
+		return rel
+	}
+
+	if file == root {
+		return filepath.ToSlash(root)
+	}
+	return path.Join(filepath.ToSlash(root), rel)
+}
+
+// Builds the canonical manifest which is one line per entry sorted by path
+// with the size, digest and optionally the mode of every file
+func treeManifest() string {
+	treeMutex.Lock()
+	defer treeMutex.Unlock()
+
+	// Every field is compared so the order and the digest never depend on the order
+	// the entries were recorded in even if the same path was recorded twice
+	sort.Slice(treeEntries, func(i, j int) bool {
+		a, b := treeEntries[i], treeEntries[j]
+		switch {
+		case a.Path != b.Path:
+			return a.Path < b.Path
+		case a.Dir != b.Dir:
+			return b.Dir
+		case a.Size != b.Size:
+			return a.Size < b.Size
+		case a.Digest != b.Digest:
+			return a.Digest < b.Digest
+		}
+		return a.Mode < b.Mode
+	})
+
+	var str strings.Builder
+	for _, e := range treeEntries {
+		if e.Dir {
+			if TreeHashEmptyDirs {
+				str.WriteString(fmt.Sprintf("d %q\n", e.Path+"/"))
+			}
+			continue
+		}
+
+		if TreeHashModes {
+			str.WriteString(fmt.Sprintf("f %s %d %s %q\n", e.Mode, e.Size, e.Digest, e.Path))
+		} else {
+			str.WriteString(fmt.Sprintf("f %d %s %q\n", e.Size, e.Digest, e.Path))
+		}
+	}
+
+	return str.String()
+}
+
+// Returns the count of files and total bytes that make up the tree
+func treeTotals() (int, int64) {
+	treeMutex.Lock()
+	defer treeMutex.Unlock()
+
+	count := 0
+	var total int64
+	for _, e := range treeEntries {
+		if !e.Dir {
+			count++
+			total += e.Size
+		}
+	}
+
+	return count, total
+}
+
+// The SHA256 of the canonical manifest
+func treeDigest() string {
+	d := sha256.New()
+	d.Write([]byte(treeManifest()))
+	return hex.EncodeToString(d.Sum(nil))
+}
+
+// Calculates the digest of the tree and formats it to match the output format
+func toTreeHash() string {
+	digest := treeDigest()
+
+	count, total := treeTotals()
+	label := strings.Join(DirFilePaths, " ")
+
+	switch {
+	case jsonFormat():
+		jsonString, _ := json.Marshal(struct {
+			Tree   string
+			SHA256 string
+			Files  int
+			Bytes  int64
+		}{label, digest, count, total})
+		return string(jsonString) + "\n"
+	case strings.ToLower(Format) == "sum" && TreeHashOnly:
+		return fmt.Sprintf("%s  %s\n", digest, label)
+	case footerPrefix() != "":
+		return fmt.Sprintf("%stree sha256 %s %s\n", footerPrefix(), digest, label)
+	}
+
+	return fmt.Sprintf("%s (tree %d files, %d bytes)\n       TREE %s\n", label, count, total, digest)
+}
@@ -0,0 +1,96 @@
+package processor
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTreeManifestPath(t *testing.T) {
+	defer func() {
+		DirFilePaths = []string{}
+	}()
+
+	DirFilePaths = []string{"a"}
+	if res := treeManifestPath("a/x"); res != "x" {
+		t.Errorf("Expected x got %s", res)
+	}
+
+	DirFilePaths = []string{"a", "b", "c.txt"}
+	cases := map[string]string{
+		"a/x":   "a/x",
+		"b/x":   "b/x",
+		"c.txt": "c.txt",
+	}
+
+	for file, expected := range cases {
+		if res := treeManifestPath(file); res != expected {
+			t.Errorf("Expected %s for %s got %s", expected, file, res)
+		}
+	}
+}
+
+func TestTreeDigest(t *testing.T) {
+	defer func() {
+		treeEntries = []treeEntry{}
+		TreeHashModes, TreeHashEmptyDirs = false, false
+	}()
+
+	entries := []treeEntry{
+		{Path: "b/x", Size: 5, Mode: "-rw-r--r--", Digest: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+		{Path: "a/x", Size: 5, Mode: "-rwxr-xr-x", Digest: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+		{Path: "empty", Dir: true},
+		{Path: "a/x", Size: 0, Mode: "-rw-r--r--", Digest: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+	}
+
+	cases := []struct {
+		modes    bool
+		empty    bool
+		expected string
+	}{
+		{false, false, "ed81537013ea693f25051f9beac0175e028bb3f2306afdb68e3c0fc75d41ff5d"},
+		{true, false, "1d97cf0bd48653d181aefcac524db96854a4573ee7e8540ca422d151db1d5c5c"},
+		{false, true, "e7e6f36ab363a4dd8ecceb784d28fe67ba3c640b1cbf655c6daeaac550626e06"},
+		{true, true, "b43aed297c1521c01f46fee57b3db29c96141032a51bab9dcefae229ca673aab"},
+	}
+
+	for _, c := range cases {
+		TreeHashModes, TreeHashEmptyDirs = c.modes, c.empty
+
+		// The digest must not depend on the order the entries were recorded in
+		for _, order := range [][]int{{0, 1, 2, 3}, {3, 2, 1, 0}, {1, 3, 0, 2}} {
+			treeEntries = []treeEntry{}
+			for _, i := range order {
+				treeEntries = append(treeEntries, entries[i])
+			}
+
+			if res := treeDigest(); res != c.expected {
+				t.Errorf("Expected %s with modes %t empty dirs %t got %s", c.expected, c.modes, c.empty, res)
+			}
+		}
+	}
+}
+
+func TestTreeHashKeepsRequestedHashes(t *testing.T) {
+	dir := processTestDir(t)
+	defer os.RemoveAll(dir)
+	defer func() {
+		TreeHash = false
+	}()
+
+	Hash = []string{HashNames.MD5}
+	Format = "sum"
+	TreeHash = true
+	output := processOutput(t, dir)
+
+	// Only the footer has a SHA256 as it was calculated for the digest but not asked for
+	if strings.Count(output, "  "+dir) != 3 {
+		t.Errorf("Expected 3 md5 lines got %s", output)
+	}
+	if strings.Contains(output, "87428fc522803d31065e7bce3cf03fe475096631e5e07bbd7a0fde60c4cf25c7") {
+		t.Errorf("Expected no sha256 lines got %s", output)
+	}
+	if !strings.Contains(output, "## tree sha256 ") {
+		t.Errorf("Expected tree footer got %s", output)
+	}
+}
@@ -1,7 +1,6 @@
 package processor
 
 import (
-	"bufio"
 	"bytes"
 	"crypto/md5"
 	"crypto/sha1"
@@ -12,850 +11,535 @@ import (
 	"github.com/minio/blake2b-simd"
 	"golang.org/x/crypto/md4"
 	"golang.org/x/crypto/sha3"
+	"hash"
 	"io"
-	"log"
+	"io/ioutil"
 	"os"
 	"sync"
+	"sync/atomic"
 )
 
-func fileProcessorWorker(input chan string, output chan Result) {
-	for res := range input {
-		if Debug {
-			printDebug(fmt.Sprintf("processing %s", res))
-		}
+// A file waiting to be processed and where its results should go. When the
+// output is ordered every file has its own results channel which is closed
+// once all of its results have been sent so they can be read back in order
+type fileJob struct {
+	path    string
+	results chan Result
+	ordered bool
+	resumed bool
+}
 
-		// Open the file and determine if we should read it from disk or memory map
-		// based on how large it is reported as being
-		file, err := os.OpenFile(res, os.O_RDONLY, 0644)
+func (j fileJob) done() {
+	if j.ordered {
+		close(j.results)
+	}
+}
 
-		if err != nil {
-			printError(fmt.Sprintf("Unable to process file %s with error %s", res, err.Error()))
-			continue
+func fileProcessorWorker(input chan string, output chan Result) {
+	jobs := make(chan fileJob)
+	go func() {
+		for path := range input {
+			jobs <- fileJob{path: path, results: output}
 		}
+		close(jobs)
+	}()
 
-		fi, err := file.Stat()
-
-		if err != nil {
-			printError(fmt.Sprintf("Unable to get file info for file %s with error %s", res, err.Error()))
-			continue
-		}
+	fileJobWorker(jobs)
+}
 
-		fsize := fi.Size()
+func fileJobWorker(input chan fileJob) {
+	// Files which have been read and are still being hashed
+	var pending sync.WaitGroup
 
-		if fsize > StreamSize {
-			if Debug {
-				printDebug(fmt.Sprintf("%s bytes=%d using scanner", res, fsize))
-			}
+	for job := range input {
+		if !processFile(job, &pending) {
+			job.done()
+		}
+	}
 
-			fileStartTime := makeTimestampMilli()
-			r, err := processScanner(res)
-			if Trace {
-				printTrace(fmt.Sprintf("milliseconds processMemoryMap: %s: %d", res, makeTimestampMilli()-fileStartTime))
-			}
+	pending.Wait()
+}
 
-			if err == nil {
-				r.File = res
-				r.Bytes = fsize
-				output <- r
-			}
+// Processes a single file sending its results to the job returning true if the
+// file was handed off to be hashed in the background which finishes the job itself
+func processFile(job fileJob, pending *sync.WaitGroup) bool {
+	res := job.path
+	progressFile(res)
 
-		} else {
-			if Debug {
-				printDebug(fmt.Sprintf("%s bytes=%d using read file", res, fsize))
-			}
+	if Debug {
+		printDebug(fmt.Sprintf("processing %s", res))
+	}
 
-			fileStartTime := makeTimestampNano()
+	if handleSymlink(res, job.results) {
+		return false
+	}
 
-			var n int64 = bytes.MinRead
-			if size := fsize + bytes.MinRead; size > n {
-				n = size
-			}
-			content, _ := readAll(file, n)
+	if Archives && isArchive(res) {
+		processArchive(res, job.results)
+		return false
+	}
 
-			var r Result
+	// Open the file and determine if we should read it from disk or memory map
+	// based on how large it is reported as being
+	file, err := os.OpenFile(res, os.O_RDONLY, 0644)
 
-			// For larger files if we have more than one hash try parallel
-			if fsize > 200000 && len(Hash) >= 1 && !hasHash("all") {
-				r, err = processReadFileParallel(res, &content)
-			} else {
-				r, err = processReadFile(res, &content)
-			}
+	if err != nil {
+		recordError(res, fmt.Sprintf("Unable to process file %s with error %s", res, err.Error()))
+		return false
+	}
 
-			if Trace {
-				printTrace(fmt.Sprintf("nanoseconds processReadFileParallel: %s: %d", res, makeTimestampNano()-fileStartTime))
-			}
+	fi, err := file.Stat()
 
-			if err == nil {
-				r.File = res
-				r.Bytes = fsize
-				output <- r
-			}
-		}
+	if err != nil {
+		recordError(res, fmt.Sprintf("Unable to get file info for file %s with error %s", res, err.Error()))
 		_ = file.Close()
+		return false
 	}
-}
 
-// TODO compare this to memory maps
-// Random tests indicate that mmap is faster when not in power save mode
-func processScanner(filename string) (Result, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		printError(fmt.Sprintf("opening file %s: %s", filename, err.Error()))
-		return Result{}, err
-	}
-	defer file.Close()
-
-	md4_d := md4.New()
-	md5_d := md5.New()
-	sha1_d := sha1.New()
-	sha256_d := sha256.New()
-	sha512_d := sha512.New()
-	blake2b_256_d := blake2b.New256()
-	blake2b_512_d := blake2b.New512()
-	sha3_224_d := sha3.New224()
-	sha3_256_d := sha3.New256()
-	sha3_384_d := sha3.New384()
-	sha3_512_d := sha3.New512()
-
-	md4c := make(chan []byte, 10)
-	md5c := make(chan []byte, 10)
-	sha1c := make(chan []byte, 10)
-	sha256c := make(chan []byte, 10)
-	sha512c := make(chan []byte, 10)
-	blake2b_256_c := make(chan []byte, 10)
-	blake2b_512_c := make(chan []byte, 10)
-	sha3_224_c := make(chan []byte, 10)
-	sha3_256_c := make(chan []byte, 10)
-	sha3_384_c := make(chan []byte, 10)
-	sha3_512_c := make(chan []byte, 10)
-
-	var wg sync.WaitGroup
+	fsize := fi.Size()
 
-	if hasHash(HashNames.MD4) {
-		wg.Add(1)
-		go func() {
-			for b := range md4c {
-				md4_d.Write(b)
-			}
-			wg.Done()
-		}()
+	if Decompress != "" && processDecompress(res, file, job.results) {
+		_ = file.Close()
+		return false
 	}
 
-	if hasHash(HashNames.MD5) {
-		wg.Add(1)
-		go func() {
-			for b := range md5c {
-				md5_d.Write(b)
-			}
-			wg.Done()
-		}()
-	}
+	// Block devices, regions and aligned reads are always streamed and never cached
+	if needsRegion(fi) {
+		r, err := processRegion(res, file, fi)
+		_ = file.Close()
+		if err != nil {
+			recordError(res, fmt.Sprintf("Unable to process file %s with error %s", res, err.Error()))
+			return false
+		}
 
-	if hasHash(HashNames.SHA1) {
-		wg.Add(1)
-		go func() {
-			for b := range sha1c {
-				sha1_d.Write(b)
-			}
-			wg.Done()
-		}()
+		job.results <- r
+		return false
 	}
 
-	if hasHash(HashNames.SHA256) {
-		wg.Add(1)
-		go func() {
-			for b := range sha256c {
-				sha256_d.Write(b)
+	// Only the first path of a hard linked inode is hashed with the others reusing its result
+	link, first := hardlinkClaim(res, fi)
+	if link != nil && !first {
+		if r, ok := link.wait(res); ok {
+			if Debug {
+				printDebug(fmt.Sprintf("%s bytes=%d hardlink of %s", res, fsize, r.HardlinkOf))
 			}
-			wg.Done()
-		}()
-	}
 
-	if hasHash(HashNames.SHA512) {
-		wg.Add(1)
-		go func() {
-			for b := range sha512c {
-				sha512_d.Write(b)
-			}
-			wg.Done()
-		}()
+			progressSkip(fsize)
+			job.results <- r
+			_ = file.Close()
+			return false
+		}
+		link = nil
 	}
 
-	if hasHash(HashNames.Blake2b256) {
-		wg.Add(1)
-		go func() {
-			for b := range blake2b_256_c {
-				blake2b_256_d.Write(b)
-			}
-			wg.Done()
-		}()
-	}
+	if r, ok := cacheLookup(res, fi); ok {
+		if Debug {
+			printDebug(fmt.Sprintf("%s bytes=%d using cache", res, fsize))
+		}
 
-	if hasHash(HashNames.Blake2b512) {
-		wg.Add(1)
-		go func() {
-			for b := range blake2b_512_c {
-				blake2b_512_d.Write(b)
-			}
-			wg.Done()
-		}()
+		r.File = res
+		r.Bytes = fsize
+		progressSkip(fsize)
+		link.finish(r, true)
+		job.results <- r
+		_ = file.Close()
+		return false
 	}
 
-	if hasHash(HashNames.Sha3224) {
-		wg.Add(1)
-		go func() {
-			for b := range sha3_224_c {
-				sha3_224_d.Write(b)
-			}
-			wg.Done()
-		}()
-	}
-	if hasHash(HashNames.Sha3256) {
-		wg.Add(1)
-		go func() {
-			for b := range sha3_256_c {
-				sha3_256_d.Write(b)
-			}
-			wg.Done()
-		}()
-	}
-	if hasHash(HashNames.Sha3384) {
-		wg.Add(1)
-		go func() {
-			for b := range sha3_384_c {
-				sha3_384_d.Write(b)
-			}
-			wg.Done()
-		}()
-	}
-	if hasHash(HashNames.Sha3512) {
-		wg.Add(1)
-		go func() {
-			for b := range sha3_512_c {
-				sha3_512_d.Write(b)
-			}
-			wg.Done()
-		}()
-	}
+	strategy := fileStrategy(fi)
+	var r Result
 
-	data := make([]byte, 4194304)
-	for {
-		n, err := file.Read(data)
-		if err != nil && err != io.EOF {
-			printError(fmt.Sprintf("reading file %s: %s", filename, err.Error()))
-			return Result{}, err
+	if strategy == "mmap" {
+		if Debug {
+			printDebug(fmt.Sprintf("%s bytes=%d using memory map", res, fsize))
 		}
 
-		// Need to make a copy here as it can be modified before
-		// the goroutine processes it in the channel
-		tmp := make([]byte, len(data))
-		copy(tmp, data)
-
-		if hasHash(HashNames.MD4) {
-			md4c <- tmp[:n]
-		}
-		if hasHash(HashNames.MD5) {
-			md5c <- tmp[:n]
-		}
-		if hasHash(HashNames.SHA1) {
-			sha1c <- tmp[:n]
-		}
-		if hasHash(HashNames.SHA256) {
-			sha256c <- tmp[:n]
-		}
-		if hasHash(HashNames.SHA512) {
-			sha512c <- tmp[:n]
-		}
-		if hasHash(HashNames.Blake2b256) {
-			blake2b_256_c <- tmp[:n]
-		}
-		if hasHash(HashNames.Blake2b512) {
-			blake2b_512_c <- tmp[:n]
-		}
-		if hasHash(HashNames.Sha3224) {
-			sha3_224_c <- tmp[:n]
-		}
-		if hasHash(HashNames.Sha3256) {
-			sha3_256_c <- tmp[:n]
-		}
-		if hasHash(HashNames.Sha3384) {
-			sha3_384_c <- tmp[:n]
-		}
-		if hasHash(HashNames.Sha3512) {
-			sha3_512_c <- tmp[:n]
+		fileStartTime := makeTimestampNano()
+		r, err = processMemoryMap(res, file)
+		if Trace {
+			printTrace(fmt.Sprintf("nanoseconds processMemoryMap: %s: %d", res, makeTimestampNano()-fileStartTime))
 		}
 
-		if err == io.EOF {
-			break
+		if err != nil {
+			if Verbose {
+				printVerbose(fmt.Sprintf("unable to memory map %s falling back to stream: %s", res, err.Error()))
+			}
+			strategy = "stream"
 		}
 	}
 
-	close(md4c)
-	close(md5c)
-	close(sha1c)
-	close(sha256c)
-	close(sha512c)
-	close(blake2b_256_c)
-	close(blake2b_512_c)
-	close(sha3_224_c)
-	close(sha3_256_c)
-	close(sha3_384_c)
-	close(sha3_512_c)
-
-	wg.Wait()
-
-	return Result{
-		File:       filename,
-		Bytes:      0,
-		MD4:        hex.EncodeToString(md4_d.Sum(nil)),
-		MD5:        hex.EncodeToString(md5_d.Sum(nil)),
-		SHA1:       hex.EncodeToString(sha1_d.Sum(nil)),
-		SHA256:     hex.EncodeToString(sha256_d.Sum(nil)),
-		SHA512:     hex.EncodeToString(sha512_d.Sum(nil)),
-		Blake2b256: hex.EncodeToString(blake2b_256_d.Sum(nil)),
-		Blake2b512: hex.EncodeToString(blake2b_512_d.Sum(nil)),
-		Sha3224:    hex.EncodeToString(sha3_224_d.Sum(nil)),
-		Sha3256:    hex.EncodeToString(sha3_256_d.Sum(nil)),
-		Sha3384:    hex.EncodeToString(sha3_384_d.Sum(nil)),
-		Sha3512:    hex.EncodeToString(sha3_512_d.Sum(nil)),
-	}, nil
-}
-
-func processStandardInput(output chan Result) {
-	total, nChunks := int64(0), int64(0)
-	r := bufio.NewReader(os.Stdin)
-	buf := make([]byte, 0, 4*1024)
-
-	md4_d := md4.New()
-	md5_d := md5.New()
-	sha1_d := sha1.New()
-	sha256_d := sha256.New()
-	sha512_d := sha512.New()
-	blake2b_256_d := blake2b.New256()
-	blake2b_512_d := blake2b.New512()
-	sha3_224_d := sha3.New224()
-	sha3_256_d := sha3.New256()
-	sha3_384_d := sha3.New384()
-	sha3_512_d := sha3.New512()
-
-	md4c := make(chan []byte, 10)
-	md5c := make(chan []byte, 10)
-	sha1c := make(chan []byte, 10)
-	sha256c := make(chan []byte, 10)
-	sha512c := make(chan []byte, 10)
-	blake2b_256_c := make(chan []byte, 10)
-	blake2b_512_c := make(chan []byte, 10)
-	sha3_224_c := make(chan []byte, 10)
-	sha3_256_c := make(chan []byte, 10)
-	sha3_384_c := make(chan []byte, 10)
-	sha3_512_c := make(chan []byte, 10)
+	if strategy == "stream" {
+		if Debug {
+			printDebug(fmt.Sprintf("%s bytes=%d using scanner", res, fsize))
+		}
 
-	var wg sync.WaitGroup
+		fileStartTime := makeTimestampMilli()
+		// The file is already open so stream it directly rather than opening it again
+		r, err = processStream(res, meteredReader{file})
+		if Trace {
+			printTrace(fmt.Sprintf("milliseconds processStream: %s: %d", res, makeTimestampMilli()-fileStartTime))
+		}
 
-	if hasHash(HashNames.MD4) {
-		wg.Add(1)
-		go func() {
-			for b := range md4c {
-				md4_d.Write(b)
-			}
-			wg.Done()
-		}()
-	}
+		if err != nil {
+			recordError(res, fmt.Sprintf("Unable to process file %s with error %s", res, err.Error()))
+		}
 
-	if hasHash(HashNames.MD5) {
-		wg.Add(1)
-		go func() {
-			for b := range md5c {
-				md5_d.Write(b)
-			}
-			wg.Done()
-		}()
+		if err == nil && hasPerceptualHash() {
+			acquireHashThread()
+			processPerceptual(res, file, &r)
+			releaseHashThread()
+		}
 	}
 
-	if hasHash(HashNames.SHA1) {
-		wg.Add(1)
-		go func() {
-			for b := range sha1c {
-				sha1_d.Write(b)
-			}
-			wg.Done()
-		}()
-	}
+	if strategy == "read" {
+		if Debug {
+			printDebug(fmt.Sprintf("%s bytes=%d using read file", res, fsize))
+		}
 
-	if hasHash(HashNames.SHA256) {
-		wg.Add(1)
-		go func() {
-			for b := range sha256c {
-				sha256_d.Write(b)
+		content, err := readContent(meteredReader{file}, fsize)
+		_ = file.Close()
+		if err != nil {
+			recordError(res, fmt.Sprintf("Unable to read file %s with error %s", res, err.Error()))
+			link.finish(Result{}, false)
+			return false
+		}
+
+		// Hash the content in the background so the reader can move onto the
+		// next file, waiting only if too many files are already in memory
+		pendingFiles <- struct{}{}
+		pending.Add(1)
+		go func(res string, fsize int64, content []byte) {
+			r, err := processContent(res, fsize, &content)
+			link.finish(r, err == nil)
+			if err == nil {
+				cacheStore(res, fi, r)
+				job.results <- r
 			}
-			wg.Done()
-		}()
+			<-pendingFiles
+			job.done()
+			pending.Done()
+		}(res, fsize, content)
+		return true
 	}
 
-	if hasHash(HashNames.SHA512) {
-		wg.Add(1)
-		go func() {
-			for b := range sha512c {
-				sha512_d.Write(b)
-			}
-			wg.Done()
-		}()
+	if err == nil {
+		r.File = res
+		r.Bytes = fsize
+		cacheStore(res, fi, r)
 	}
-
-	if hasHash(HashNames.Blake2b256) {
-		wg.Add(1)
-		go func() {
-			for b := range blake2b_256_c {
-				blake2b_256_d.Write(b)
-			}
-			wg.Done()
-		}()
+	link.finish(r, err == nil)
+	if err == nil {
+		job.results <- r
 	}
+	_ = file.Close()
+	return false
+}
 
-	if hasHash(HashNames.Blake2b512) {
-		wg.Add(1)
-		go func() {
-			for b := range blake2b_512_c {
-				blake2b_512_d.Write(b)
-			}
-			wg.Done()
-		}()
+// Reads the whole of a file into memory. Hashing part of a file would report
+// it as fine so it is an error if the read failed or the file changed size
+// while it was being read
+func readContent(reader io.Reader, fsize int64) ([]byte, error) {
+	var n int64 = bytes.MinRead
+	if size := fsize + bytes.MinRead; size > n {
+		n = size
 	}
 
-	if hasHash(HashNames.Sha3224) {
-		wg.Add(1)
-		go func() {
-			for b := range sha3_224_c {
-				sha3_224_d.Write(b)
-			}
-			wg.Done()
-		}()
-	}
-	if hasHash(HashNames.Sha3256) {
-		wg.Add(1)
-		go func() {
-			for b := range sha3_256_c {
-				sha3_256_d.Write(b)
-			}
-			wg.Done()
-		}()
-	}
-	if hasHash(HashNames.Sha3384) {
-		wg.Add(1)
-		go func() {
-			for b := range sha3_384_c {
-				sha3_384_d.Write(b)
-			}
-			wg.Done()
-		}()
+	content, err := readAll(reader, n)
+	if err != nil {
+		return nil, err
 	}
-	if hasHash(HashNames.Sha3512) {
-		wg.Add(1)
-		go func() {
-			for b := range sha3_512_c {
-				sha3_512_d.Write(b)
-			}
-			wg.Done()
-		}()
+	if int64(len(content)) != fsize {
+		return nil, fmt.Errorf("read %d bytes expected %d", len(content), fsize)
 	}
 
-	for {
-		n, err := r.Read(buf[:cap(buf)])
-		buf = buf[:n]
+	return content, nil
+}
 
-		if n == 0 {
-			if err == nil {
-				continue
-			}
+// Hashes content which has already been read into memory
+func processContent(filename string, fsize int64, content *[]byte) (Result, error) {
+	fileStartTime := makeTimestampNano()
 
-			if err == io.EOF {
-				break
-			}
+	var r Result
+	var err error
 
-			log.Fatal(err)
-		}
+	// For larger files if we have more than one hash try parallel
+	if fsize > 200000 && len(Hash) >= 1 && !hasHash("all") {
+		r, err = processReadFileParallel(filename, content)
+	} else {
+		r, err = processReadFile(filename, content)
+	}
 
-		nChunks++
-		total += int64(len(buf))
+	if Trace {
+		printTrace(fmt.Sprintf("nanoseconds processReadFileParallel: %s: %d", filename, makeTimestampNano()-fileStartTime))
+	}
 
-		if hasHash(HashNames.MD4) {
-			md4c <- buf
-		}
-		if hasHash(HashNames.MD5) {
-			md5c <- buf
-		}
-		if hasHash(HashNames.SHA1) {
-			sha1c <- buf
-		}
-		if hasHash(HashNames.SHA256) {
-			sha256c <- buf
-		}
-		if hasHash(HashNames.SHA512) {
-			sha512c <- buf
-		}
-		if hasHash(HashNames.Blake2b256) {
-			blake2b_256_c <- buf
-		}
-		if hasHash(HashNames.Blake2b512) {
-			blake2b_512_c <- buf
-		}
-		if hasHash(HashNames.Sha3224) {
-			sha3_224_c <- buf
-		}
-		if hasHash(HashNames.Sha3256) {
-			sha3_256_c <- buf
-		}
-		if hasHash(HashNames.Sha3384) {
-			sha3_384_c <- buf
-		}
-		if hasHash(HashNames.Sha3512) {
-			sha3_512_c <- buf
-		}
+	if err != nil {
+		return r, err
+	}
 
-		if err != nil && err != io.EOF {
-			log.Fatal(err)
+	if hasPerceptualHash() || Dedup {
+		acquireHashThread()
+		if hasPerceptualHash() {
+			processPerceptual(filename, bytes.NewReader(*content), &r)
+		}
+		if Dedup {
+			r.Chunks = processChunks(content)
 		}
+		releaseHashThread()
 	}
 
-	close(md4c)
-	close(md5c)
-	close(sha1c)
-	close(sha256c)
-	close(sha512c)
-	close(blake2b_256_c)
-	close(blake2b_512_c)
-	close(sha3_224_c)
-	close(sha3_256_c)
-	close(sha3_384_c)
-	close(sha3_512_c)
-
-	wg.Wait()
+	r.File = filename
+	r.Bytes = fsize
+	return r, nil
+}
 
-	output <- Result{
-		File:       "stdin",
-		Bytes:      total,
-		MD4:        hex.EncodeToString(md4_d.Sum(nil)),
-		MD5:        hex.EncodeToString(md5_d.Sum(nil)),
-		SHA1:       hex.EncodeToString(sha1_d.Sum(nil)),
-		SHA256:     hex.EncodeToString(sha256_d.Sum(nil)),
-		SHA512:     hex.EncodeToString(sha512_d.Sum(nil)),
-		Blake2b256: hex.EncodeToString(blake2b_256_d.Sum(nil)),
-		Blake2b512: hex.EncodeToString(blake2b_512_d.Sum(nil)),
-		Sha3224:    hex.EncodeToString(sha3_224_d.Sum(nil)),
-		Sha3256:    hex.EncodeToString(sha3_256_d.Sum(nil)),
-		Sha3384:    hex.EncodeToString(sha3_384_d.Sum(nil)),
-		Sha3512:    hex.EncodeToString(sha3_512_d.Sum(nil)),
+// Determines how the file should be read based on the strategy and its size
+func fileStrategy(fi os.FileInfo) string {
+	switch Strategy {
+	case "read", "stream":
+		return Strategy
+	case "mmap":
+		if canMemoryMap(fi) {
+			return "mmap"
+		}
+		return "stream"
 	}
 
-	close(output)
+	if fi.Size() <= StreamSize {
+		return "read"
+	}
+	// Streaming spreads the reads out evenly when limiting the rate
+	if maxRateBytes <= 0 && canMemoryMap(fi) {
+		return "mmap"
+	}
+	return "stream"
 }
 
-// For files under a certain size its faster to just read them into memory in one
-// chunk and then process them which this method does
-// NB there is little point in multi-processing at this level, it would be
-// better done on the input channel if required
-func processReadFileParallel(filename string, content *[]byte) (Result, error) {
-	startTime := makeTimestampNano()
-
-	var wg sync.WaitGroup
-	result := Result{}
+// Size of each read when streaming a file through the hashes
+var streamBufferSize = 4194304
 
-	if hasHash(HashNames.MD4) {
-		wg.Add(1)
-		go func() {
-			startTime = makeTimestampNano()
-			d := md4.New()
-			d.Write(*content)
-			result.MD4 = hex.EncodeToString(d.Sum(nil))
+// How many buffers each hash can fall behind the reader before the reader waits
+const streamQueueDepth = 4
 
-			if Trace {
-				printTrace(fmt.Sprintf("nanoseconds processing md4: %s: %d", filename, makeTimestampNano()-startTime))
-			}
-			wg.Done()
-		}()
-	}
+// A buffer filled by a single read which is shared by every hash without
+// copying and returned to the pool once the last of them has finished
+type streamBuffer struct {
+	data []byte
+	n    int
+	refs int32
+}
 
-	if hasHash(HashNames.MD5) {
-		wg.Add(1)
-		go func() {
-			startTime = makeTimestampNano()
-			d := md5.New()
-			d.Write(*content)
-			result.MD5 = hex.EncodeToString(d.Sum(nil))
+var streamBufferPool = sync.Pool{
+	New: func() interface{} {
+		return &streamBuffer{data: make([]byte, streamBufferSize)}
+	},
+}
 
-			if Trace {
-				printTrace(fmt.Sprintf("nanoseconds processing md5: %s: %d", filename, makeTimestampNano()-startTime))
-			}
-			wg.Done()
-		}()
+func (b *streamBuffer) release() {
+	if atomic.AddInt32(&b.refs, -1) == 0 {
+		streamBufferPool.Put(b)
 	}
+}
 
-	if hasHash(HashNames.SHA1) {
-		wg.Add(1)
-		go func() {
-			startTime = makeTimestampNano()
-			d := sha1.New()
-			d.Write(*content)
-			result.SHA1 = hex.EncodeToString(d.Sum(nil))
-
-			if Trace {
-				printTrace(fmt.Sprintf("nanoseconds processing sha1: %s: %d", filename, makeTimestampNano()-startTime))
-			}
-			wg.Done()
-		}()
-	}
+// Every hash which can be calculated over a stream along with where its result is stored
+type streamHasher struct {
+	name string
+	new  func() hash.Hash
+	get  func(Result) string
+	set  func(*Result, string)
+}
 
-	if hasHash(HashNames.SHA256) {
-		wg.Add(1)
-		go func() {
-			startTime = makeTimestampNano()
-			d := sha256.New()
-			d.Write(*content)
-			result.SHA256 = hex.EncodeToString(d.Sum(nil))
+var streamHashers = []streamHasher{
+	{HashNames.MD4, md4.New, func(r Result) string { return r.MD4 }, func(r *Result, s string) { r.MD4 = s }},
+	{HashNames.MD5, md5.New, func(r Result) string { return r.MD5 }, func(r *Result, s string) { r.MD5 = s }},
+	{HashNames.SHA1, sha1.New, func(r Result) string { return r.SHA1 }, func(r *Result, s string) { r.SHA1 = s }},
+	{HashNames.SHA256, sha256.New, func(r Result) string { return r.SHA256 }, func(r *Result, s string) { r.SHA256 = s }},
+	{HashNames.SHA512, sha512.New, func(r Result) string { return r.SHA512 }, func(r *Result, s string) { r.SHA512 = s }},
+	{HashNames.Blake2b256, blake2b.New256, func(r Result) string { return r.Blake2b256 }, func(r *Result, s string) { r.Blake2b256 = s }},
+	{HashNames.Blake2b512, blake2b.New512, func(r Result) string { return r.Blake2b512 }, func(r *Result, s string) { r.Blake2b512 = s }},
+	{HashNames.Sha3224, sha3.New224, func(r Result) string { return r.Sha3224 }, func(r *Result, s string) { r.Sha3224 = s }},
+	{HashNames.Sha3256, sha3.New256, func(r Result) string { return r.Sha3256 }, func(r *Result, s string) { r.Sha3256 = s }},
+	{HashNames.Sha3384, sha3.New384, func(r Result) string { return r.Sha3384 }, func(r *Result, s string) { r.Sha3384 = s }},
+	{HashNames.Sha3512, sha3.New512, func(r Result) string { return r.Sha3512 }, func(r *Result, s string) { r.Sha3512 = s }},
+}
 
-			if Trace {
-				printTrace(fmt.Sprintf("nanoseconds processing sha256: %s: %d", filename, makeTimestampNano()-startTime))
-			}
-			wg.Done()
-		}()
-	}
+// Reads from the reader into pooled buffers handing each to a goroutine per
+// hash so that anything which can be read as a stream can be hashed without
+// holding it all in memory. Each buffer is read once and shared by every
+// hash rather than copied for each of them
+func processStream(filename string, reader io.Reader) (Result, error) {
+	result := Result{File: filename}
+	var consumers []chan *streamBuffer
+	var wg sync.WaitGroup
 
-	if hasHash(HashNames.SHA512) {
-		wg.Add(1)
-		go func() {
-			startTime = makeTimestampNano()
-			d := sha512.New()
-			d.Write(*content)
-			result.SHA512 = hex.EncodeToString(d.Sum(nil))
+	for _, h := range streamHashers {
+		if !calculateHash(h.name) {
+			continue
+		}
 
-			if Trace {
-				printTrace(fmt.Sprintf("nanoseconds processing sha512: %s: %d", filename, makeTimestampNano()-startTime))
-			}
-			wg.Done()
-		}()
-	}
+		h := h
+		input := make(chan *streamBuffer, streamQueueDepth)
+		consumers = append(consumers, input)
 
-	if hasHash(HashNames.Blake2b256) {
 		wg.Add(1)
 		go func() {
-			startTime = makeTimestampNano()
-			d := blake2b.New256()
-			d.Write(*content)
-			result.Blake2b256 = hex.EncodeToString(d.Sum(nil))
-
-			if Trace {
-				printTrace(fmt.Sprintf("nanoseconds processing blake2b-256: %s: %d", filename, makeTimestampNano()-startTime))
+			d := h.new()
+			for b := range input {
+				acquireHashThread()
+				d.Write(b.data[:b.n])
+				releaseHashThread()
+				b.release()
 			}
+			h.set(&result, hex.EncodeToString(d.Sum(nil)))
 			wg.Done()
 		}()
 	}
 
-	if hasHash(HashNames.Blake2b512) {
-		wg.Add(1)
-		go func() {
-			startTime = makeTimestampNano()
-			d := blake2b.New512()
-			d.Write(*content)
-			result.Blake2b512 = hex.EncodeToString(d.Sum(nil))
-
-			if Trace {
-				printTrace(fmt.Sprintf("nanoseconds processing blake2b-512: %s: %d", filename, makeTimestampNano()-startTime))
-			}
-			wg.Done()
-		}()
-	}
+	if Dedup {
+		input := make(chan *streamBuffer, streamQueueDepth)
+		consumers = append(consumers, input)
 
-	if hasHash(HashNames.Sha3224) {
 		wg.Add(1)
 		go func() {
-			startTime = makeTimestampNano()
-			d := sha3.New224()
-			d.Write(*content)
-			result.Sha3224 = hex.EncodeToString(d.Sum(nil))
-
-			if Trace {
-				printTrace(fmt.Sprintf("nanoseconds processing sha3-224: %s: %d", filename, makeTimestampNano()-startTime))
+			c := newChunker()
+			for b := range input {
+				acquireHashThread()
+				_, _ = c.Write(b.data[:b.n])
+				releaseHashThread()
+				b.release()
 			}
+			result.Chunks = c.Close()
 			wg.Done()
 		}()
 	}
 
-	if hasHash(HashNames.Sha3256) {
-		wg.Add(1)
-		go func() {
-			startTime = makeTimestampNano()
-			d := sha3.New256()
-			d.Write(*content)
-			result.Sha3256 = hex.EncodeToString(d.Sum(nil))
+	var err error
+	for {
+		b := streamBufferPool.Get().(*streamBuffer)
+		// The buffer size can change when benchmarking so older buffers are replaced
+		if len(b.data) != streamBufferSize {
+			b.data = make([]byte, streamBufferSize)
+		}
+		n, readErr := fillBuffer(reader, b.data)
+		b.n = n
+		result.Bytes += int64(n)
 
-			if Trace {
-				printTrace(fmt.Sprintf("nanoseconds processing sha3-256: %s: %d", filename, makeTimestampNano()-startTime))
+		if n != 0 && len(consumers) != 0 {
+			b.refs = int32(len(consumers))
+			for _, c := range consumers {
+				c <- b
 			}
-			wg.Done()
-		}()
-	}
-
-	if hasHash(HashNames.Sha3384) {
-		wg.Add(1)
-		go func() {
-			startTime = makeTimestampNano()
-			d := sha3.New384()
-			d.Write(*content)
-			result.Sha3384 = hex.EncodeToString(d.Sum(nil))
+		} else {
+			streamBufferPool.Put(b)
+		}
 
-			if Trace {
-				printTrace(fmt.Sprintf("nanoseconds processing sha3-384: %s: %d", filename, makeTimestampNano()-startTime))
-			}
-			wg.Done()
-		}()
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			err = readErr
+			break
+		}
 	}
 
-	if hasHash(HashNames.Sha3512) {
-		wg.Add(1)
-		go func() {
-			startTime = makeTimestampNano()
-			d := sha3.New512()
-			d.Write(*content)
-			result.Sha3512 = hex.EncodeToString(d.Sum(nil))
+	// Always close so the hashes finish and return their buffers even on error
+	for _, c := range consumers {
+		close(c)
+	}
+	wg.Wait()
 
-			if Trace {
-				printTrace(fmt.Sprintf("nanoseconds processing sha3-512: %s: %d", filename, makeTimestampNano()-startTime))
-			}
-			wg.Done()
-		}()
+	if err != nil {
+		return Result{}, fmt.Errorf("reading file %s: %s", filename, err.Error())
 	}
 
-	wg.Wait()
 	return result, nil
 }
 
-func processReadFile(filename string, content *[]byte) (Result, error) {
-	startTime := makeTimestampNano()
-
-	result := Result{}
-
-	if hasHash(HashNames.MD4) {
-		startTime = makeTimestampNano()
-		d := md4.New()
-		d.Write(*content)
-		result.MD4 = hex.EncodeToString(d.Sum(nil))
-
-		if Trace {
-			printTrace(fmt.Sprintf("nanoseconds processing md4: %s: %d", filename, makeTimestampNano()-startTime))
+// Fills the buffer the same way as io.ReadFull but returns the error from the reader as is
+// so a truncated stream such as a gzip ending with io.ErrUnexpectedEOF is not taken as the end
+func fillBuffer(reader io.Reader, buf []byte) (int, error) {
+	n := 0
+	for n < len(buf) {
+		read, err := reader.Read(buf[n:])
+		n += read
+		if err != nil {
+			return n, err
 		}
 	}
+	return n, nil
+}
 
-	if hasHash(HashNames.MD5) {
-		startTime = makeTimestampNano()
-		d := md5.New()
-		d.Write(*content)
-		result.MD5 = hex.EncodeToString(d.Sum(nil))
-
-		if Trace {
-			printTrace(fmt.Sprintf("nanoseconds processing md5: %s: %d", filename, makeTimestampNano()-startTime))
+func processStandardInput(output chan Result) {
+	var reader io.Reader = meteredReader{os.Stdin}
+	if offsetBytes != 0 {
+		if _, err := io.CopyN(ioutil.Discard, reader, offsetBytes); err != nil {
+			recordError("stdin", fmt.Sprintf("Unable to skip to offset %d of stdin with error %s", offsetBytes, err.Error()))
+			close(output)
+			return
 		}
 	}
-
-	if hasHash(HashNames.SHA1) {
-		startTime = makeTimestampNano()
-		d := sha1.New()
-		d.Write(*content)
-		result.SHA1 = hex.EncodeToString(d.Sum(nil))
-
-		if Trace {
-			printTrace(fmt.Sprintf("nanoseconds processing sha1: %s: %d", filename, makeTimestampNano()-startTime))
-		}
+	if lengthBytes >= 0 {
+		reader = io.LimitReader(reader, lengthBytes)
 	}
 
-	if hasHash(HashNames.SHA256) {
-		startTime = makeTimestampNano()
-		d := sha256.New()
-		d.Write(*content)
-		result.SHA256 = hex.EncodeToString(d.Sum(nil))
-
-		if Trace {
-			printTrace(fmt.Sprintf("nanoseconds processing sha256: %s: %d", filename, makeTimestampNano()-startTime))
-		}
+	res, err := processStream(regionName("stdin", lengthBytes), reader)
+	if err != nil {
+		recordError("stdin", fmt.Sprintf("Unable to process stdin with error %s", err.Error()))
+	} else {
+		output <- res
 	}
 
-	if hasHash(HashNames.SHA512) {
-		startTime = makeTimestampNano()
-		d := sha512.New()
-		d.Write(*content)
-		result.SHA512 = hex.EncodeToString(d.Sum(nil))
-
-		if Trace {
-			printTrace(fmt.Sprintf("nanoseconds processing sha512: %s: %d", filename, makeTimestampNano()-startTime))
-		}
-	}
+	close(output)
+}
 
-	if hasHash(HashNames.Blake2b256) {
-		startTime = makeTimestampNano()
-		d := blake2b.New256()
-		d.Write(*content)
-		result.Blake2b256 = hex.EncodeToString(d.Sum(nil))
+// For files under a certain size its faster to just read them into memory in one
+// chunk and then process them which this method does running each hash in parallel
+func processReadFileParallel(filename string, content *[]byte) (Result, error) {
+	var wg sync.WaitGroup
+	result := Result{}
 
-		if Trace {
-			printTrace(fmt.Sprintf("nanoseconds processing blake2b-256: %s: %d", filename, makeTimestampNano()-startTime))
+	for _, h := range streamHashers {
+		if !calculateHash(h.name) {
+			continue
 		}
-	}
-
-	if hasHash(HashNames.Blake2b512) {
-		startTime = makeTimestampNano()
-		d := blake2b.New512()
-		d.Write(*content)
-		result.Blake2b512 = hex.EncodeToString(d.Sum(nil))
 
-		if Trace {
-			printTrace(fmt.Sprintf("nanoseconds processing blake2b-512: %s: %d", filename, makeTimestampNano()-startTime))
-		}
+		h := h
+		wg.Add(1)
+		go func() {
+			hashContent(filename, h, content, &result)
+			wg.Done()
+		}()
 	}
 
-	if hasHash(HashNames.Sha3224) {
-		startTime = makeTimestampNano()
-		d := sha3.New224()
-		d.Write(*content)
-		result.Sha3224 = hex.EncodeToString(d.Sum(nil))
-
-		if Trace {
-			printTrace(fmt.Sprintf("nanoseconds processing sha3-224: %s: %d", filename, makeTimestampNano()-startTime))
-		}
-	}
+	wg.Wait()
+	return result, nil
+}
 
-	if hasHash(HashNames.Sha3256) {
-		startTime = makeTimestampNano()
-		d := sha3.New256()
-		d.Write(*content)
-		result.Sha3256 = hex.EncodeToString(d.Sum(nil))
+func processReadFile(filename string, content *[]byte) (Result, error) {
+	result := Result{}
 
-		if Trace {
-			printTrace(fmt.Sprintf("nanoseconds processing sha3-256: %s: %d", filename, makeTimestampNano()-startTime))
+	for _, h := range streamHashers {
+		if calculateHash(h.name) {
+			hashContent(filename, h, content, &result)
 		}
 	}
 
-	if hasHash(HashNames.Sha3384) {
-		startTime = makeTimestampNano()
-		d := sha3.New384()
-		d.Write(*content)
-		result.Sha3384 = hex.EncodeToString(d.Sum(nil))
+	return result, nil
+}
 
-		if Trace {
-			printTrace(fmt.Sprintf("nanoseconds processing sha3-384: %s: %d", filename, makeTimestampNano()-startTime))
-		}
-	}
+// Calculates a single hash over content which is in memory while holding a hash thread
+func hashContent(filename string, h streamHasher, content *[]byte, result *Result) {
+	acquireHashThread()
+	defer releaseHashThread()
 
-	if hasHash(HashNames.Sha3512) {
-		startTime = makeTimestampNano()
-		d := sha3.New512()
-		d.Write(*content)
-		result.Sha3512 = hex.EncodeToString(d.Sum(nil))
+	startTime := makeTimestampNano()
+	d := h.new()
+	d.Write(*content)
+	h.set(result, hex.EncodeToString(d.Sum(nil)))
 
-		if Trace {
-			printTrace(fmt.Sprintf("nanoseconds processing sha3-512: %s: %d", filename, makeTimestampNano()-startTime))
-		}
+	if Trace {
+		printTrace(fmt.Sprintf("nanoseconds processing %s: %s: %d", h.name, filename, makeTimestampNano()-startTime))
 	}
-
-	return result, nil
 }
 
 // Copied from Go io/ioutil
@@ -1,7 +1,13 @@
 package processor
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"testing"
+	"testing/iotest"
 )
 
 func TestProcessReadFile(t *testing.T) {
@@ -21,6 +27,113 @@ func TestProcessReadFile(t *testing.T) {
 	}
 }
 
+func TestReadContentErrors(t *testing.T) {
+	if _, err := readContent(bytes.NewReader([]byte("short")), 10); err == nil {
+		t.Error("Expected error for a short read")
+	}
+
+	if _, err := readContent(iotest.TimeoutReader(bytes.NewReader(make([]byte, 1024))), 1024); err == nil {
+		t.Error("Expected error for a failed read")
+	}
+
+	content, err := readContent(bytes.NewReader([]byte("exact")), 5)
+	if err != nil || string(content) != "exact" {
+		t.Errorf("Expected exact got %s %v", content, err)
+	}
+}
+
+// Hashes the same file with each strategy returning the results in the order read, stream, mmap
+func hashStrategies(t *testing.T, data []byte) []Result {
+	file, err := ioutil.TempFile("", "hashit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	_, _ = file.Write(data)
+	_ = file.Close()
+
+	var results []Result
+	for _, strategy := range []string{"read", "stream", "mmap"} {
+		file, _ := os.Open(file.Name())
+
+		var res Result
+		switch strategy {
+		case "read":
+			content, _ := readAll(file, int64(len(data))+bytes.MinRead)
+			res, err = processContent(file.Name(), int64(len(content)), &content)
+		case "stream":
+			res, err = processStream(file.Name(), file)
+		case "mmap":
+			if len(data) == 0 {
+				// Empty files cannot be mapped and are streamed instead
+				res, err = processStream(file.Name(), file)
+			} else {
+				res, err = processMemoryMap(file.Name(), file)
+			}
+		}
+		_ = file.Close()
+
+		if err != nil {
+			t.Errorf("Expected no error for %s got %s", strategy, err.Error())
+		}
+		results = append(results, res)
+	}
+
+	return results
+}
+
+func TestStrategiesAgreeAtBufferBoundary(t *testing.T) {
+	previousHash, previousBuffer := Hash, streamBufferSize
+	defer func() {
+		Hash, streamBufferSize = previousHash, previousBuffer
+	}()
+	Hash = []string{HashNames.MD5, HashNames.SHA256, HashNames.Blake2b512}
+	streamBufferSize = 64
+
+	for _, size := range []int{0, 1, streamBufferSize - 1, streamBufferSize, streamBufferSize + 1} {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i)
+		}
+		expected := fmt.Sprintf("%x", sha256.Sum256(data))
+
+		for i, res := range hashStrategies(t, data) {
+			if res.SHA256 != expected {
+				t.Errorf("Expected %s for strategy %d at %d bytes got %s", expected, i, size, res.SHA256)
+			}
+			if res.MD5 == "" || res.Blake2b512 == "" {
+				t.Errorf("Expected every hash for strategy %d at %d bytes got %v", i, size, res)
+			}
+		}
+	}
+}
+
+func TestStreamBufferSizeChange(t *testing.T) {
+	previousHash, previousBuffer := Hash, streamBufferSize
+	defer func() {
+		Hash, streamBufferSize = previousHash, previousBuffer
+	}()
+	Hash = []string{HashNames.SHA256}
+
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	expected := fmt.Sprintf("%x", sha256.Sum256(data))
+
+	// Buffers pooled at one size must not be reused at another
+	for _, size := range []int{64, 300, 16, 4096} {
+		streamBufferSize = size
+		res, err := processStream("filename", bytes.NewReader(data))
+		if err != nil || res.SHA256 != expected {
+			t.Errorf("Expected %s with buffer %d got %s %v", expected, size, res.SHA256, err)
+		}
+		if res.Bytes != int64(len(data)) {
+			t.Errorf("Expected %d bytes with buffer %d got %d", len(data), size, res.Bytes)
+		}
+	}
+}
+
 //////////////////////////////////////////////////
 // Benchmarks Below
 //////////////////////////////////////////////////
@@ -150,3 +263,89 @@ func BenchmarkProcessReadFileSingle1000Bytes(b *testing.B) {
 	}
 	b.Log(count)
 }
+
+func benchmarkProcessStream(b *testing.B, hashes []string) {
+	b.StopTimer()
+	previous := Hash
+	Hash = hashes
+	defer func() {
+		Hash = previous
+	}()
+
+	data := make([]byte, 64*1024*1024)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	b.SetBytes(int64(len(data)))
+	b.StartTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, _ = processStream("filename", bytes.NewReader(data))
+	}
+}
+
+func BenchmarkProcessStreamOneHash(b *testing.B) {
+	benchmarkProcessStream(b, []string{HashNames.SHA256})
+}
+
+func BenchmarkProcessStreamFourHashes(b *testing.B) {
+	benchmarkProcessStream(b, []string{HashNames.MD5, HashNames.SHA1, HashNames.SHA256, HashNames.Blake2b512})
+}
+
+func BenchmarkProcessStreamAllHashes(b *testing.B) {
+	benchmarkProcessStream(b, []string{"all"})
+}
+
+func benchmarkStrategy(b *testing.B, strategy string) {
+	b.StopTimer()
+	previous := Hash
+	Hash = []string{HashNames.MD5, HashNames.SHA256}
+	defer func() {
+		Hash = previous
+	}()
+
+	file, err := ioutil.TempFile("", "hashit")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	data := make([]byte, 64*1024*1024)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	_, _ = file.Write(data)
+	_ = file.Close()
+
+	b.SetBytes(int64(len(data)))
+	b.StartTimer()
+
+	for i := 0; i < b.N; i++ {
+		file, _ := os.Open(file.Name())
+
+		switch strategy {
+		case "read":
+			content, _ := readAll(file, int64(len(data))+bytes.MinRead)
+			_, _ = processReadFileParallel(file.Name(), &content)
+		case "stream":
+			_, _ = processStream(file.Name(), file)
+		case "mmap":
+			_, _ = processMemoryMap(file.Name(), file)
+		}
+
+		_ = file.Close()
+	}
+}
+
+func BenchmarkStrategyRead(b *testing.B) {
+	benchmarkStrategy(b, "read")
+}
+
+func BenchmarkStrategyStream(b *testing.B) {
+	benchmarkStrategy(b, "stream")
+}
+
+func BenchmarkStrategyMemoryMap(b *testing.B) {
+	benchmarkStrategy(b, "mmap")
+}
@@ -145,7 +145,7 @@ else
     exit
 fi
 
-if echo "hello" | ./hashit main.go --hash md5 | grep -q -i 'b1946ac92492d2347c6235b4d2611184'; then
+if echo "hello" | ./hashit --hash md5 | grep -q -i 'b1946ac92492d2347c6235b4d2611184'; then
     echo -e "${GREEN}PASSED stdin md5 test"
 else
     echo -e "${RED}======================================================="
@@ -154,7 +154,7 @@ else
     exit
 fi
 
-if echo "hello" | ./hashit main.go --hash sha1 | grep -q -i 'f572d396fae9206628714fb2ce00f72e94f2258f'; then
+if echo "hello" | ./hashit --hash sha1 | grep -q -i 'f572d396fae9206628714fb2ce00f72e94f2258f'; then
     echo -e "${GREEN}PASSED stdin sha1 test"
 else
     echo -e "${RED}======================================================="
@@ -163,7 +163,7 @@ else
     exit
 fi
 
-if echo "hello" | ./hashit main.go --hash sha256 | grep -q -i '5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03'; then
+if echo "hello" | ./hashit --hash sha256 | grep -q -i '5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03'; then
     echo -e "${GREEN}PASSED stdin sha256 test"
 else
     echo -e "${RED}======================================================="
@@ -172,6 +172,42 @@ else
     exit
 fi
 
+if echo "hello" | ./hashit - --hash md5 | grep -q -i 'b1946ac92492d2347c6235b4d2611184'; then
+    echo -e "${GREEN}PASSED stdin dash test"
+else
+    echo -e "${RED}======================================================="
+    echo -e "FAILED Should be able to process stdin using -"
+    echo -e "======================================================="
+    exit
+fi
+
+if echo "hello" | ./hashit main.go --hash md5 | grep -q -i 'b1946ac92492d2347c6235b4d2611184'; then
+    echo -e "${RED}======================================================="
+    echo -e "FAILED Explicit files should be processed instead of stdin"
+    echo -e "======================================================="
+    exit
+else
+    echo -e "${GREEN}PASSED explicit files over stdin test"
+fi
+
+if ./hashit processor main.go | grep -q -i 'processor/workers.go'; then
+    echo -e "${GREEN}PASSED multiple arguments recurse test"
+else
+    echo -e "${RED}======================================================="
+    echo -e "FAILED Directories should be walked with multiple arguments"
+    echo -e "======================================================="
+    exit
+fi
+
+if ./hashit --no-recursive processor main.go 2>&1 | grep -q -i 'processor/workers.go'; then
+    echo -e "${RED}======================================================="
+    echo -e "FAILED Directories should be skipped with --no-recursive"
+    echo -e "======================================================="
+    exit
+else
+    echo -e "${GREEN}PASSED no recursive test"
+fi
+
 a=$(./hashit --no-stream * | sort | md5sum)
 b=$(./hashit * | sort | md5sum)
 if [ "$a" == "$b" ]; then